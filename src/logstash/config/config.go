@@ -10,13 +10,13 @@ import (
 
 // [BP]/defaults/templates/templates.yml
 type TemplatesConfig struct {
-	Set         bool             `yaml:"-"`
 	Alias 		Alias            `yaml:"alias"`
+	LabelAliases map[string]Alias `yaml:"label-aliases"`
 	Templates []Template         `yaml:"templates"`
 }
 
 type Alias struct {
-	Set                      bool   `yaml:"-"`
+	set                      keySet
 	CredentialsHostField     string `yaml:"credentials-host-field"`
 	CredentialsUsernameField string `yaml:"credentials-username-field"`
 	CredentialsPasswordField string `yaml:"credentials-password-field"`
@@ -29,7 +29,14 @@ type Template struct {
 	Tags                []string `yaml:"tags"`
 	Groks               []string `yaml:"groks"`
 	Plugins             []string `yaml:"plugins"`
+	Alias               Alias    `yaml:"alias"`
+	LabelAliases        map[string]Alias `yaml:"label-aliases"`
+	Params              []TemplateParam `yaml:"params"`
+	ParamValues         map[string]string `yaml:"-"`
+	Dir                 string   `yaml:"-"` // directory of <name>.conf
 	ServiceInstanceName string   `yaml:"-"`
+	FallbackFor         string   `yaml:"-"` // name of the template replaced by this fallback template
+	Credentials         Credentials `yaml:"-"`
 }
 
 func (c *TemplatesConfig) Parse(data []byte) (err error) {
@@ -44,9 +51,12 @@ func (c *TemplatesConfig) Parse(data []byte) (err error) {
 
 // [APP]Logstash
 type LogstashConfig struct {
-	Set                   bool             `yaml:"-"`
+	set                   keySet
 	Version               string           `yaml:"version"`
-	Plugins               []string         `yaml:"plugins"`
+	Plugins               []Plugin         `yaml:"plugins"`
+	RemovePlugins         []string         `yaml:"remove-plugins"`
+	KeepPlugins           []string         `yaml:"keep-plugins"`
+	Minimal               bool             `yaml:"minimal"`
 	Certificates          []string         `yaml:"certificates"`
 	CmdArgs               string           `yaml:"cmd-args"`
 	JavaOpts              string           `yaml:"java-opts"`
@@ -54,25 +64,60 @@ type LogstashConfig struct {
 	HeapPercentage        int              `yaml:"heap-percentage"`
 	ConfigCheck           bool             `yaml:"config-check"`
 	ConfigTemplates       []ConfigTemplate `yaml:"config-templates"`
+	Pipelines             []Pipeline       `yaml:"pipelines"`
+	Queue                 Queue            `yaml:"queue"`
+	Settings              map[string]interface{} `yaml:"settings"`
 	EnableServiceFallback bool             `yaml:"enable-service-fallback"`
+	ServiceSelection      ServiceSelection `yaml:"service-selection"`
 	Curator               Curator          `yaml:"curator"`
+	HealthCheck           HealthCheck      `yaml:"health-check"`
 	Buildpack             Buildpack        `yaml:"buildpack"`
 }
 
 type Buildpack struct {
-    Set                   bool 			   `yaml:"-"`
+	set                   keySet
 	LogLevel              string           `yaml:"log-level"`
 	NoCache               bool             `yaml:"no-cache"`
+	Offline               bool             `yaml:"offline"`
 	DoSleepCommand        bool             `yaml:"sleep-command"`
 }
 
 type ConfigTemplate struct {
-	Name                string `yaml:"name"`
-	ServiceInstanceName string `yaml:"service-instance-name"`
+	Name                string            `yaml:"name"`
+	ServiceInstanceName string            `yaml:"service-instance-name"`
+	Params              map[string]string `yaml:"params"`
+}
+
+// A Pipeline is run by Logstash in parallel to the other pipelines, with its
+// own configuration (files in ConfigDir and config templates) and settings.
+type Pipeline struct {
+	ID              string           `yaml:"id"`
+	ConfigDir       string           `yaml:"config-dir"`
+	Workers         int              `yaml:"workers"`
+	BatchSize       int              `yaml:"batch-size"`
+	QueueType       string           `yaml:"queue-type"`
+	ConfigTemplates []ConfigTemplate `yaml:"config-templates"`
+}
+
+// Queue defines the queue of the pipelines and the dead letter queues. Sizes
+// are in MB, if not set they are derived from the disk quota of the app.
+type Queue struct {
+	Type                    string `yaml:"type"`
+	MaxBytes                int    `yaml:"max-bytes"`
+	DeadLetterQueue         bool   `yaml:"dead-letter-queue"`
+	DeadLetterQueueMaxBytes int    `yaml:"dead-letter-queue-max-bytes"`
+}
+
+// HealthCheck defines the sidecar which owns $PORT: it serves /health and
+// forwards all other requests to the Logstash input on InputPort.
+type HealthCheck struct {
+	set       keySet
+	Enabled   bool `yaml:"enabled"`
+	InputPort int  `yaml:"input-port"`
 }
 
 type Curator struct {
-	Set      bool   `yaml:"-"`
+	set      keySet
 	Install  bool   `yaml:"install"`
 	Schedule string `yaml:"schedule"`
 }
@@ -148,21 +193,18 @@ func (s *VcapServices) WithTags(tags []string) []VcapService {
 	return result
 }
 
-func (s *VcapServices) UserProvided() []VcapService {
-	result := []VcapService{}
-	for service , service_instances := range *s {
-		if service == "user-provided" {
-			for i := range service_instances {
-				service_instance := service_instances[i]
-				result = append(result, service_instance)
+// ByName returns the service instance with the given name.
+func (s *VcapServices) ByName(name string) (VcapService, bool) {
+	for _, service_instances := range *s {
+		for _, service_instance := range service_instances {
+			if service_instance.Name == name {
+				return service_instance, true
 			}
 		}
 	}
-
-	return result
+	return VcapService{}, false
 }
 
-
 func (c *VcapServices) Parse(data []byte) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
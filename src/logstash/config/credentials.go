@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Shapes of the credentials of a service instance
+const (
+	CredentialsURI    = "uri"    // a single URL with embedded userinfo
+	CredentialsHosts  = "hosts"  // an array of hosts
+	CredentialsFields = "fields" // separate host, username and password fields
+)
+
+// hostFields are tried in this order if the credentials do not contain the
+// host field of the alias.
+var hostFields = []string{"uri", "url", "hosts", "host"}
+
+// Credentials describes the credentials of a service instance: their shape and
+// the fields used by the templates. UsernameField and PasswordField are empty
+// if the credentials are embedded in the URL or there are none.
+type Credentials struct {
+	Shape         string
+	HostField     string
+	UsernameField string
+	PasswordField string
+}
+
+// AliasFor returns the credential field aliases for the template t bound to a
+// service instance with the given label. Fields are taken from (most specific
+// first) the label aliases of the template, the alias of the template, the
+// label aliases of the templates file and the alias of the templates file.
+func (c *TemplatesConfig) AliasFor(t Template, label string) Alias {
+	alias := c.Alias
+	alias.merge(c.LabelAliases[label])
+	alias.merge(t.Alias)
+	alias.merge(t.LabelAliases[label])
+	return alias
+}
+
+// merge overrides the fields of a with the fields set in o.
+func (a *Alias) merge(o Alias) {
+	if o.IsSet("credentials-host-field") {
+		a.CredentialsHostField = o.CredentialsHostField
+	}
+	if o.IsSet("credentials-username-field") {
+		a.CredentialsUsernameField = o.CredentialsUsernameField
+	}
+	if o.IsSet("credentials-password-field") {
+		a.CredentialsPasswordField = o.CredentialsPasswordField
+	}
+}
+
+// DetectCredentials detects the shape of the credentials of a service instance
+// with the field names of the alias. The host field of the alias is used if it
+// exists, otherwise the first of uri, url, hosts and host.
+func DetectCredentials(credentials map[string]interface{}, alias Alias) (Credentials, error) {
+	c := Credentials{HostField: alias.CredentialsHostField}
+	if credentials[c.HostField] == nil {
+		c.HostField = ""
+		for _, f := range hostFields {
+			if credentials[f] != nil {
+				c.HostField = f
+				break
+			}
+		}
+	}
+	if c.HostField == "" {
+		tried := []string{alias.CredentialsHostField}
+		for _, f := range hostFields {
+			if f != alias.CredentialsHostField {
+				tried = append(tried, f)
+			}
+		}
+		return c, fmt.Errorf("no host field found in the credentials (tried %s)", strings.Join(tried, ", "))
+	}
+
+	var first string
+	switch hosts := credentials[c.HostField].(type) {
+	case string:
+		c.Shape, first = CredentialsFields, hosts
+	case []interface{}:
+		if len(hosts) == 0 {
+			return c, fmt.Errorf("credential %q is an empty list", c.HostField)
+		}
+		c.Shape, first = CredentialsHosts, fmt.Sprint(hosts[0])
+	default:
+		return c, fmt.Errorf("credential %q is neither a string nor a list of strings", c.HostField)
+	}
+
+	if u, err := url.Parse(first); err == nil && u.User != nil && u.User.Username() != "" {
+		// credentials embedded in the URL, separate fields would conflict
+		if c.Shape == CredentialsFields {
+			c.Shape = CredentialsURI
+		}
+		return c, nil
+	}
+	if credentials[alias.CredentialsUsernameField] != nil {
+		c.UsernameField = alias.CredentialsUsernameField
+	}
+	if credentials[alias.CredentialsPasswordField] != nil {
+		c.PasswordField = alias.CredentialsPasswordField
+	}
+	return c, nil
+}
@@ -0,0 +1,69 @@
+package config_test
+
+import (
+	conf "logstash/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Credentials", func() {
+	alias := conf.Alias{CredentialsHostField: "host", CredentialsUsernameField: "username", CredentialsPasswordField: "password"}
+
+	It("detects a URL with userinfo", func() {
+		c, err := conf.DetectCredentials(map[string]interface{}{"uri": "https://user:secret@es:9200", "username": "user"}, alias)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c).To(Equal(conf.Credentials{Shape: conf.CredentialsURI, HostField: "uri"}))
+	})
+
+	It("detects an array of hosts", func() {
+		c, err := conf.DetectCredentials(map[string]interface{}{"hosts": []interface{}{"https://es1:9200", "https://es2:9200"}, "username": "user", "password": "secret"}, alias)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c).To(Equal(conf.Credentials{Shape: conf.CredentialsHosts, HostField: "hosts", UsernameField: "username", PasswordField: "password"}))
+	})
+
+	It("detects separate fields", func() {
+		c, err := conf.DetectCredentials(map[string]interface{}{"host": "https://es:9200", "username": "user", "password": "secret"}, alias)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c).To(Equal(conf.Credentials{Shape: conf.CredentialsFields, HostField: "host", UsernameField: "username", PasswordField: "password"}))
+
+		c, err = conf.DetectCredentials(map[string]interface{}{"url": "http://es:9200"}, alias)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c).To(Equal(conf.Credentials{Shape: conf.CredentialsFields, HostField: "url"}))
+	})
+
+	It("fails without host field", func() {
+		_, err := conf.DetectCredentials(map[string]interface{}{"sslcert": "..."}, alias)
+		Expect(err).To(MatchError("no host field found in the credentials (tried host, uri, url, hosts)"))
+		_, err = conf.DetectCredentials(map[string]interface{}{"hosts": []interface{}{}}, alias)
+		Expect(err).To(MatchError(`credential "hosts" is an empty list`))
+	})
+
+	It("resolves the aliases per template and label", func() {
+		tc := conf.TemplatesConfig{}
+		Expect(tc.Parse([]byte(`
+alias:
+  credentials-host-field: host
+label-aliases:
+  a9s-elasticsearch:
+    credentials-host-field: host_ip
+    credentials-username-field: user
+templates:
+- name: cf-output-elasticsearch
+  alias:
+    credentials-password-field: pass
+  label-aliases:
+    a9s-elasticsearch:
+      credentials-host-field: hosts
+`))).To(Succeed())
+		tc.Alias.ApplyDefaults()
+		t := tc.Templates[0]
+
+		Expect(tc.AliasFor(t, "elasticsearch")).To(WithTransform(fields, Equal([]string{"host", "username", "pass"})))
+		Expect(tc.AliasFor(t, "a9s-elasticsearch")).To(WithTransform(fields, Equal([]string{"hosts", "user", "pass"})))
+	})
+})
+
+func fields(a conf.Alias) []string {
+	return []string{a.CredentialsHostField, a.CredentialsUsernameField, a.CredentialsPasswordField}
+}
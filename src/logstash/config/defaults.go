@@ -0,0 +1,242 @@
+package config
+
+// Documented defaults of the Logstash file
+const (
+	DefaultReservedMemory        = 300
+	DefaultHeapPercentage        = 75
+	DefaultConfigCheck           = true
+	DefaultEnableServiceFallback = false
+	DefaultMinimal               = false
+	DefaultCuratorInstall        = false
+	DefaultCuratorSchedule       = "@daily"
+	DefaultLogLevel              = "Info"
+	DefaultNoCache               = false
+	DefaultPipelinesDir          = "pipelines"
+	DefaultHealthCheckEnabled    = false
+	DefaultHealthCheckInputPort  = 8081
+	DefaultAPIPort               = 9600
+	DefaultPipelineID            = "main"
+	DefaultServiceSelectionMode  = ServiceSelectionSingle
+)
+
+// Defaults of the templates file
+const (
+	DefaultCredentialsHostField     = "host"
+	DefaultCredentialsUsernameField = "username"
+	DefaultCredentialsPasswordField = "password"
+)
+
+// keySet records which keys of a yaml mapping have been set explicitly
+// (to a non-null value), to tell absent settings apart from zero values.
+type keySet map[string]bool
+
+func (s keySet) has(key string) bool {
+	return s[key]
+}
+
+func (s *keySet) add(key string) {
+	if *s == nil {
+		*s = keySet{}
+	}
+	(*s)[key] = true
+}
+
+// readKeySet returns the keys of the yaml mapping the unmarshal function is
+// bound to.
+func readKeySet(unmarshal func(interface{}) error) (keySet, error) {
+	var mapping map[string]interface{}
+	if err := unmarshal(&mapping); err != nil {
+		return nil, err
+	}
+
+	keys := keySet{}
+	for key, value := range mapping {
+		if value != nil {
+			keys[key] = true
+		}
+	}
+	return keys, nil
+}
+
+func (c *LogstashConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain LogstashConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	// log-level is the deprecated alias of buildpack.log-level
+	var deprecated struct {
+		LogLevel *string `yaml:"log-level"`
+	}
+	if err := unmarshal(&deprecated); err != nil {
+		return err
+	}
+	if deprecated.LogLevel != nil && !c.Buildpack.IsSet("log-level") {
+		c.Buildpack.LogLevel = *deprecated.LogLevel
+		c.Buildpack.markSet("log-level")
+	}
+
+	keys, err := readKeySet(unmarshal)
+	c.set = keys
+	return err
+}
+
+// IsSet returns true if the setting is defined in the Logstash file or
+// overridden by an environment variable.
+func (c *LogstashConfig) IsSet(key string) bool {
+	return c.set.has(key)
+}
+
+func (c *LogstashConfig) markSet(key string) {
+	c.set.add(key)
+}
+
+// ApplyDefaults sets the documented default for every setting which is
+// neither defined in the Logstash file nor overridden by the environment.
+func (c *LogstashConfig) ApplyDefaults() {
+	if !c.IsSet("reserved-memory") {
+		c.ReservedMemory = DefaultReservedMemory
+	}
+	if !c.IsSet("heap-percentage") {
+		c.HeapPercentage = DefaultHeapPercentage
+	}
+	if !c.IsSet("config-check") {
+		c.ConfigCheck = DefaultConfigCheck
+	}
+	if !c.IsSet("enable-service-fallback") {
+		c.EnableServiceFallback = DefaultEnableServiceFallback
+	}
+	if !c.IsSet("minimal") {
+		c.Minimal = DefaultMinimal
+	}
+	if c.ServiceSelection.Mode == "" {
+		c.ServiceSelection.Mode = DefaultServiceSelectionMode
+	}
+	if c.Queue.Type == "" {
+		c.Queue.Type = DefaultQueueType
+	}
+	for i := range c.Pipelines {
+		if c.Pipelines[i].ConfigDir == "" {
+			c.Pipelines[i].ConfigDir = DefaultPipelinesDir + "/" + c.Pipelines[i].ID
+		}
+	}
+
+	c.Curator.ApplyDefaults()
+	c.HealthCheck.ApplyDefaults()
+	c.Buildpack.ApplyDefaults()
+}
+
+func (c *Curator) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Curator
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	keys, err := readKeySet(unmarshal)
+	c.set = keys
+	return err
+}
+
+// IsSet returns true if the setting is defined in the curator section.
+func (c *Curator) IsSet(key string) bool {
+	return c.set.has(key)
+}
+
+func (c *Curator) markSet(key string) {
+	c.set.add(key)
+}
+
+func (c *Curator) ApplyDefaults() {
+	if !c.IsSet("install") {
+		c.Install = DefaultCuratorInstall
+	}
+	if !c.IsSet("schedule") || c.Schedule == "" {
+		c.Schedule = DefaultCuratorSchedule
+	}
+}
+
+func (h *HealthCheck) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain HealthCheck
+	if err := unmarshal((*plain)(h)); err != nil {
+		return err
+	}
+
+	keys, err := readKeySet(unmarshal)
+	h.set = keys
+	return err
+}
+
+// IsSet returns true if the setting is defined in the health-check section.
+func (h *HealthCheck) IsSet(key string) bool {
+	return h.set.has(key)
+}
+
+func (h *HealthCheck) markSet(key string) {
+	h.set.add(key)
+}
+
+func (h *HealthCheck) ApplyDefaults() {
+	if !h.IsSet("enabled") {
+		h.Enabled = DefaultHealthCheckEnabled
+	}
+	if !h.IsSet("input-port") {
+		h.InputPort = DefaultHealthCheckInputPort
+	}
+}
+
+func (b *Buildpack) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Buildpack
+	if err := unmarshal((*plain)(b)); err != nil {
+		return err
+	}
+
+	keys, err := readKeySet(unmarshal)
+	b.set = keys
+	return err
+}
+
+// IsSet returns true if the setting is defined in the buildpack section.
+func (b *Buildpack) IsSet(key string) bool {
+	return b.set.has(key)
+}
+
+func (b *Buildpack) markSet(key string) {
+	b.set.add(key)
+}
+
+func (b *Buildpack) ApplyDefaults() {
+	if !b.IsSet("log-level") || b.LogLevel == "" {
+		b.LogLevel = DefaultLogLevel
+	}
+	if !b.IsSet("no-cache") {
+		b.NoCache = DefaultNoCache
+	}
+}
+
+func (a *Alias) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Alias
+	if err := unmarshal((*plain)(a)); err != nil {
+		return err
+	}
+
+	keys, err := readKeySet(unmarshal)
+	a.set = keys
+	return err
+}
+
+// IsSet returns true if the alias is defined in the templates file.
+func (a *Alias) IsSet(key string) bool {
+	return a.set.has(key)
+}
+
+func (a *Alias) ApplyDefaults() {
+	if !a.IsSet("credentials-host-field") {
+		a.CredentialsHostField = DefaultCredentialsHostField
+	}
+	if !a.IsSet("credentials-username-field") {
+		a.CredentialsUsernameField = DefaultCredentialsUsernameField
+	}
+	if !a.IsSet("credentials-password-field") {
+		a.CredentialsPasswordField = DefaultCredentialsPasswordField
+	}
+}
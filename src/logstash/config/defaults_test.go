@@ -0,0 +1,125 @@
+package config_test
+
+import (
+	conf "logstash/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LogstashConfig defaults", func() {
+	var (
+		data string
+		lc   conf.LogstashConfig
+		err  error
+	)
+
+	JustBeforeEach(func() {
+		lc = conf.LogstashConfig{}
+		err = lc.Parse([]byte(data))
+		lc.ApplyDefaults()
+	})
+
+	Context("an empty Logstash file", func() {
+		BeforeEach(func() {
+			data = ""
+		})
+
+		It("applies all documented defaults", func() {
+			Expect(err).To(BeNil())
+			Expect(lc.ReservedMemory).To(Equal(300))
+			Expect(lc.HeapPercentage).To(Equal(75))
+			Expect(lc.ConfigCheck).To(BeTrue())
+			Expect(lc.EnableServiceFallback).To(BeFalse())
+			Expect(lc.Minimal).To(BeFalse())
+			Expect(lc.Curator.Install).To(BeFalse())
+			Expect(lc.Curator.Schedule).To(Equal("@daily"))
+			Expect(lc.Buildpack.LogLevel).To(Equal("Info"))
+			Expect(lc.Buildpack.NoCache).To(BeFalse())
+			Expect(lc.Buildpack.IsSet("offline")).To(BeFalse()) // derived from the buildpack at staging
+		})
+	})
+
+	Context("settings explicitly set to false or zero", func() {
+		BeforeEach(func() {
+			data = `config-check: false
+reserved-memory: 0
+curator:
+  install: false
+`
+		})
+
+		It("keeps the explicit values", func() {
+			Expect(err).To(BeNil())
+			Expect(lc.IsSet("config-check")).To(BeTrue())
+			Expect(lc.ConfigCheck).To(BeFalse())
+			Expect(lc.ReservedMemory).To(Equal(0))
+			Expect(lc.HeapPercentage).To(Equal(75))
+			Expect(lc.Curator.IsSet("install")).To(BeTrue())
+			Expect(lc.Curator.IsSet("schedule")).To(BeFalse())
+		})
+	})
+
+	Context("pipelines without config-dir", func() {
+		BeforeEach(func() {
+			data = `pipelines:
+- id: syslog
+- id: kafka
+  config-dir: kafka.conf.d
+`
+		})
+
+		It("defaults the config-dir to pipelines/<id>", func() {
+			Expect(lc.Pipelines[0].ConfigDir).To(Equal("pipelines/syslog"))
+			Expect(lc.Pipelines[1].ConfigDir).To(Equal("kafka.conf.d"))
+		})
+	})
+
+	Context("settings without a value", func() {
+		BeforeEach(func() {
+			data = `config-check:
+buildpack:
+  log-level: debug
+`
+		})
+
+		It("treats them as absent", func() {
+			Expect(lc.IsSet("config-check")).To(BeFalse())
+			Expect(lc.ConfigCheck).To(BeTrue())
+			Expect(lc.Buildpack.LogLevel).To(Equal("debug"))
+		})
+	})
+
+	Context("the deprecated top-level log-level", func() {
+		BeforeEach(func() {
+			data = "log-level: Debug\n"
+		})
+
+		It("sets buildpack.log-level", func() {
+			Expect(err).To(BeNil())
+			Expect(lc.Buildpack.IsSet("log-level")).To(BeTrue())
+			Expect(lc.Buildpack.LogLevel).To(Equal("Debug"))
+		})
+
+		Context("with buildpack.log-level", func() {
+			BeforeEach(func() {
+				data = "log-level: Debug\nbuildpack:\n  log-level: Info\n"
+			})
+
+			It("is overridden by buildpack.log-level", func() {
+				Expect(lc.Buildpack.LogLevel).To(Equal("Info"))
+			})
+		})
+	})
+})
+
+var _ = Describe("Alias defaults", func() {
+	It("only defaults the fields which are not defined", func() {
+		tc := conf.TemplatesConfig{}
+		Expect(tc.Parse([]byte("alias:\n  credentials-host-field: uri\n"))).To(Succeed())
+		tc.Alias.ApplyDefaults()
+		Expect(tc.Alias.CredentialsHostField).To(Equal("uri"))
+		Expect(tc.Alias.CredentialsUsernameField).To(Equal("username"))
+		Expect(tc.Alias.CredentialsPasswordField).To(Equal("password"))
+	})
+})
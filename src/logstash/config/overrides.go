@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is the prefix of the environment variables which override
+// settings of the Logstash file at staging.
+const EnvPrefix = "LS_BP_"
+
+// An Override is a setting of the Logstash file which has been overridden by
+// an environment variable.
+type Override struct {
+	Key      string
+	Variable string
+	Value    string
+}
+
+// EnvVariable returns the name of the environment variable which overrides
+// the setting with the given key, e.g. LS_BP_CURATOR_SCHEDULE for
+// "curator.schedule".
+func EnvVariable(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
+
+// settable is implemented by the sections of the Logstash file which track
+// their explicitly set keys.
+type settable interface {
+	markSet(key string)
+}
+
+var (
+	configTemplateType = reflect.TypeOf([]ConfigTemplate{})
+	pluginsType        = reflect.TypeOf([]Plugin{})
+	settingsType       = reflect.TypeOf(map[string]interface{}{})
+)
+
+// ApplyEnvOverrides sets every setting for which an environment variable is
+// defined (see EnvVariable). Lists are given comma separated, config templates
+// as "name[:service-instance-name]", plugins as "name[:version]" and Logstash
+// settings as "name=value". lookup is usually os.LookupEnv.
+// Overridden settings count as set, so the precedence is: environment
+// variable, Logstash file, default (ApplyDefaults).
+func (c *LogstashConfig) ApplyEnvOverrides(lookup func(string) (string, bool)) ([]Override, error) {
+	overrides := []Override{}
+	errs := ValidationErrors{}
+
+	applyEnvOverrides(reflect.ValueOf(c).Elem(), "", lookup, &overrides, &errs)
+
+	if len(errs) > 0 {
+		return overrides, errs
+	}
+	return overrides, nil
+}
+
+func applyEnvOverrides(v reflect.Value, path string, lookup func(string) (string, bool), overrides *[]Override, errs *ValidationErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if field.PkgPath != "" || name == "" || name == "-" {
+			continue
+		}
+		key := joinPath(path, name)
+		fv := v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			applyEnvOverrides(fv, key, lookup, overrides, errs)
+			continue
+		}
+
+		variable := EnvVariable(key)
+		value, ok := lookup(variable)
+		if !ok {
+			continue
+		}
+
+		if err := setFromString(fv, value); err != nil {
+			*errs = append(*errs, ValidationError{Key: key, Source: "environment variable " + variable, Message: err.Error()})
+			continue
+		}
+		if s, ok := v.Addr().Interface().(settable); ok {
+			s.markSet(name)
+		}
+		*overrides = append(*overrides, Override{Key: key, Variable: variable, Value: value})
+	}
+}
+
+// setFromString sets a setting from the string representation of an
+// environment variable.
+func setFromString(v reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+
+	if v.Type() == configTemplateType {
+		templates := []ConfigTemplate{}
+		for _, item := range splitList(value) {
+			parts := strings.SplitN(item, ":", 2)
+			ct := ConfigTemplate{Name: strings.TrimSpace(parts[0])}
+			if len(parts) == 2 {
+				ct.ServiceInstanceName = strings.TrimSpace(parts[1])
+			}
+			templates = append(templates, ct)
+		}
+		v.Set(reflect.ValueOf(templates))
+		return nil
+	}
+	if v.Type() == pluginsType {
+		plugins := []Plugin{}
+		for _, item := range splitList(value) {
+			plugins = append(plugins, ParsePlugin(item))
+		}
+		v.Set(reflect.ValueOf(plugins))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		v.SetInt(int64(i))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("setting can not be overridden by an environment variable")
+		}
+		v.Set(reflect.ValueOf(splitList(value)))
+	case reflect.Map:
+		if v.Type() != settingsType {
+			return fmt.Errorf("setting can not be overridden by an environment variable")
+		}
+		settings := map[string]interface{}{}
+		for _, item := range splitList(value) {
+			parts := strings.SplitN(item, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("%q is not a name=value pair", item)
+			}
+			// parse the value as yaml scalar to keep numbers and booleans typed
+			var setting interface{}
+			if err := yaml.Unmarshal([]byte(strings.TrimSpace(parts[1])), &setting); err != nil {
+				return fmt.Errorf("%q is not a valid value", parts[1])
+			}
+			settings[strings.TrimSpace(parts[0])] = setting
+		}
+		v.Set(reflect.ValueOf(settings))
+	default:
+		return fmt.Errorf("setting can not be overridden by an environment variable")
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
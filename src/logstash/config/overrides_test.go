@@ -0,0 +1,123 @@
+package config_test
+
+import (
+	conf "logstash/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ApplyEnvOverrides", func() {
+	var (
+		data      string
+		env       map[string]string
+		lc        conf.LogstashConfig
+		overrides []conf.Override
+		err       error
+	)
+
+	lookup := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	BeforeEach(func() {
+		data = `heap-percentage: 50
+cmd-args: "--log.level=warn"
+curator:
+  schedule: "0 0 1 * * *"
+`
+		env = map[string]string{}
+	})
+
+	JustBeforeEach(func() {
+		lc = conf.LogstashConfig{}
+		Expect(lc.Parse([]byte(data))).To(Succeed())
+		overrides, err = lc.ApplyEnvOverrides(lookup)
+		lc.ApplyDefaults()
+	})
+
+	It("derives the variable names from the setting keys", func() {
+		Expect(conf.EnvVariable("heap-percentage")).To(Equal("LS_BP_HEAP_PERCENTAGE"))
+		Expect(conf.EnvVariable("buildpack.log-level")).To(Equal("LS_BP_BUILDPACK_LOG_LEVEL"))
+	})
+
+	Context("without environment variables", func() {
+		It("keeps the Logstash file and the defaults", func() {
+			Expect(err).To(BeNil())
+			Expect(overrides).To(BeEmpty())
+			Expect(lc.HeapPercentage).To(Equal(50))
+			Expect(lc.ReservedMemory).To(Equal(300))
+		})
+	})
+
+	Context("with environment variables", func() {
+		BeforeEach(func() {
+			env = map[string]string{
+				"LS_BP_HEAP_PERCENTAGE":         "80",
+				"LS_BP_RESERVED_MEMORY":         "0",
+				"LS_BP_CMD_ARGS":                "",
+				"LS_BP_ENABLE_SERVICE_FALLBACK": "true",
+				"LS_BP_PLUGINS":                 "logstash-input-kafka, logstash-output-kafka:^7.0",
+				"LS_BP_CONFIG_TEMPLATES":        "cf-input-syslog,cf-output-elasticsearch:my-es",
+				"LS_BP_CURATOR_INSTALL":         "true",
+				"LS_BP_BUILDPACK_LOG_LEVEL":     "debug",
+				"LS_BP_BUILDPACK_OFFLINE":       "false",
+				"LS_BP_SETTINGS":                "http.port=9601, log.format=json",
+			}
+		})
+
+		It("takes precedence over the Logstash file and the defaults", func() {
+			Expect(err).To(BeNil())
+			Expect(overrides).To(HaveLen(10))
+			Expect(lc.HeapPercentage).To(Equal(80))
+			Expect(lc.ReservedMemory).To(Equal(0))
+			Expect(lc.CmdArgs).To(Equal(""))
+			Expect(lc.EnableServiceFallback).To(BeTrue())
+			Expect(lc.Plugins).To(Equal([]conf.Plugin{{Name: "logstash-input-kafka"}, {Name: "logstash-output-kafka", Version: "^7.0"}}))
+			Expect(lc.ConfigTemplates).To(Equal([]conf.ConfigTemplate{
+				{Name: "cf-input-syslog"},
+				{Name: "cf-output-elasticsearch", ServiceInstanceName: "my-es"},
+			}))
+			Expect(lc.Curator.Install).To(BeTrue())
+			Expect(lc.Curator.Schedule).To(Equal("0 0 1 * * *"))
+			Expect(lc.Buildpack.LogLevel).To(Equal("debug"))
+			Expect(lc.Buildpack.IsSet("offline")).To(BeTrue())
+			Expect(lc.Buildpack.Offline).To(BeFalse())
+		})
+	})
+
+	Context("with invalid environment variables", func() {
+		BeforeEach(func() {
+			env = map[string]string{
+				"LS_BP_HEAP_PERCENTAGE": "lots",
+				"LS_BP_CONFIG_CHECK":    "maybe",
+			}
+		})
+
+		It("reports all of them", func() {
+			errs := err.(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(2))
+			Expect(errs[0].Error()).To(Equal(`environment variable LS_BP_HEAP_PERCENTAGE: "lots" is not an integer`))
+			Expect(errs[1].Source).To(Equal("environment variable LS_BP_CONFIG_CHECK"))
+		})
+	})
+
+	Context("with values out of range", func() {
+		BeforeEach(func() {
+			env = map[string]string{
+				"LS_BP_HEAP_PERCENTAGE":    "120",
+				"LS_BP_CURATOR_SCHEDULE":   "never",
+				"LS_BP_BUILDPACK_NO_CACHE": "true",
+			}
+		})
+
+		It("reports them by ValidateOverrides", func() {
+			Expect(err).To(BeNil())
+			errs := conf.ValidateOverrides(&lc, overrides, 1024).(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(2))
+			Expect(errs[0].Source).To(Equal("environment variable LS_BP_HEAP_PERCENTAGE"))
+			Expect(errs[1].Source).To(Equal("environment variable LS_BP_CURATOR_SCHEDULE"))
+		})
+	})
+})
@@ -0,0 +1,264 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"gopkg.in/yaml.v2"
+)
+
+// LockFile is the plugin lock file of the app, written at staging.
+const LockFile = "Logstash.lock"
+
+// The sources of the plugins in the order they are searched.
+const (
+	PluginSourceXPack    = "x-pack"
+	PluginSourceDefault  = "logstash-plugins"
+	PluginSourceApp      = "app"
+	PluginSourceRubygems = "rubygems"
+)
+
+// A Plugin of the Logstash file, given as name or as "name: version" with a
+// semver constraint of the version (e.g. "^7.0", ">= 5.1.0, < 6.0.0" or 7.0.6).
+type Plugin struct {
+	Name    string
+	Version string // version constraint, empty for any version
+}
+
+func (p *Plugin) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*p = Plugin{Name: name}
+		return nil
+	}
+
+	var entry map[string]string
+	if err := unmarshal(&entry); err != nil || len(entry) != 1 {
+		return fmt.Errorf("plugins must be given as name or as name: version")
+	}
+	for name, version := range entry {
+		*p = Plugin{Name: name, Version: version}
+	}
+	return nil
+}
+
+func (p Plugin) MarshalYAML() (interface{}, error) {
+	if p.Version == "" {
+		return p.Name, nil
+	}
+	return map[string]string{p.Name: p.Version}, nil
+}
+
+// ParsePlugin parses a plugin given as "name[:version]", e.g. in an
+// environment variable.
+func ParsePlugin(s string) Plugin {
+	parts := strings.SplitN(s, ":", 2)
+	p := Plugin{Name: strings.TrimSpace(parts[0])}
+	if len(parts) == 2 {
+		p.Version = strings.TrimSpace(parts[1])
+	}
+	return p
+}
+
+// ValidateVersionConstraint checks a version constraint, an empty constraint
+// is valid.
+func ValidateVersionConstraint(constraint string) error {
+	if constraint == "" {
+		return nil
+	}
+	_, err := semver.NewConstraint(constraint)
+	return err
+}
+
+var exactVersion = regexp.MustCompile(`^=?\s*v?(\d+\.\d+\.\d+\S*)$`)
+
+// ExactVersion returns the version of a constraint which allows a single
+// version only (e.g. 7.0.6 or =7.0.6).
+func ExactVersion(constraint string) (string, bool) {
+	m := exactVersion.FindStringSubmatch(strings.TrimSpace(constraint))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// SatisfiesVersion returns whether the version satisfies the constraint, any
+// version satisfies an empty constraint.
+func SatisfiesVersion(version, constraint string) bool {
+	if constraint == "" {
+		return true
+	}
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return false
+	}
+	v, err := semver.NewVersion(version)
+	return err == nil && c.Check(v)
+}
+
+var pluginFile = regexp.MustCompile(`^(.+?)-(\d+(?:\.[0-9A-Za-z]+)*)$`)
+
+// ParsePluginFile returns the plugin name and version of a plugin file like
+// logstash-output-kafka-7.0.6.gem, logstash-input-beats-5.0.6-java.gem or
+// x-pack-6.0.0.zip.
+func ParsePluginFile(file string) (name, version string, ok bool) {
+	ext := filepath.Ext(file)
+	if ext != ".gem" && ext != ".zip" {
+		return "", "", false
+	}
+	m := pluginFile.FindStringSubmatch(strings.TrimSuffix(strings.TrimSuffix(file, ext), "-java"))
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// SelectPluginFile returns the file of the plugin with the highest version
+// satisfying the constraint. The name of the plugin must match exactly, e.g.
+// logstash-input-kafka does not match logstash-input-kafka-connect-1.0.0.gem.
+func SelectPluginFile(name, constraint string, files []string) (file, version string) {
+	var best *semver.Version
+	for _, f := range files {
+		n, v, ok := ParsePluginFile(f)
+		if !ok || n != name || !SatisfiesVersion(v, constraint) {
+			continue
+		}
+		sv, err := semver.NewVersion(v)
+		if err != nil {
+			continue
+		}
+		if best == nil || sv.GreaterThan(best) {
+			best, file, version = sv, f, v
+		}
+	}
+	return file, version
+}
+
+var gemfileEntry = regexp.MustCompile(`^\s*gem\s+["']([^"']+)["']`)
+
+// GemfilePlugins returns the gems of the Gemfile of Logstash, only these
+// plugins can be removed with logstash-plugin.
+func GemfilePlugins(gemfile string) []string {
+	plugins := []string{}
+	for _, line := range strings.Split(gemfile, "\n") {
+		if m := gemfileEntry.FindStringSubmatch(line); m != nil {
+			plugins = append(plugins, m[1])
+		}
+	}
+	return plugins
+}
+
+// PluginLock is the content of the lock file: the resolved version, the
+// source and the checksum of every installed plugin. A restage installs the
+// locked versions as long as they satisfy the version constraints.
+type PluginLock struct {
+	Logstash string         `yaml:"logstash"`
+	Plugins  []LockedPlugin `yaml:"plugins"`
+}
+
+type LockedPlugin struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	Source  string `yaml:"source"`
+	File    string `yaml:"file,omitempty"`
+	SHA256  string `yaml:"sha256,omitempty"`
+}
+
+func (l *PluginLock) Parse(data []byte) error {
+	return yaml.Unmarshal(data, l)
+}
+
+// Find returns the locked plugin with the name.
+func (l *PluginLock) Find(name string) (LockedPlugin, bool) {
+	for _, p := range l.Plugins {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return LockedPlugin{}, false
+}
+
+// Names returns the names of the plugins with their versions.
+func (l PluginLock) Names() []string {
+	names := []string{}
+	for _, p := range l.Plugins {
+		names = append(names, p.Name+" "+p.Version)
+	}
+	return names
+}
+
+// CacheKey returns a hash of the Logstash version and the plugins (name,
+// version, source and the checksum of plugin files) independent of their
+// order.
+func (l PluginLock) CacheKey() string {
+	lines := []string{"logstash " + l.Logstash}
+	for _, p := range l.Plugins {
+		checksum := ""
+		if p.File != "" {
+			checksum = p.SHA256
+		}
+		lines = append(lines, strings.Join([]string{p.Name, p.Version, p.Source, checksum}, " "))
+	}
+	sort.Strings(lines[1:])
+
+	h := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(h[:])[:16]
+}
+
+// A PluginInstall is a single run of logstash-plugin install (the arguments)
+// and the plugins it installs.
+type PluginInstall struct {
+	Args    []string
+	Plugins []LockedPlugin
+}
+
+// PluginInstalls groups the plugins into as few runs of logstash-plugin
+// install as possible, every run starts a JVM. A run installs either plugin
+// files or plugins from rubygems, a version is only accepted for a single
+// plugin and plugin packs (zip) are installed one by one. Packs are installed
+// first, they may provide plugins of the other runs.
+func PluginInstalls(plugins []LockedPlugin) []PluginInstall {
+	packs, versioned := []PluginInstall{}, []PluginInstall{}
+	files := PluginInstall{Args: []string{"install"}}
+	gems := PluginInstall{Args: []string{"install"}}
+
+	for _, p := range plugins {
+		switch {
+		case strings.HasSuffix(p.File, ".zip"):
+			packs = append(packs, PluginInstall{Args: []string{"install", "file://" + p.File}, Plugins: []LockedPlugin{p}})
+		case p.File != "":
+			files.Args = append(files.Args, p.File)
+			files.Plugins = append(files.Plugins, p)
+		case p.Version != "":
+			versioned = append(versioned, PluginInstall{Args: []string{"install", "--version", p.Version, p.Name}, Plugins: []LockedPlugin{p}})
+		default:
+			gems.Args = append(gems.Args, p.Name)
+			gems.Plugins = append(gems.Plugins, p)
+		}
+	}
+
+	installs := packs
+	if len(files.Plugins) > 0 {
+		installs = append(installs, files)
+	}
+	if len(gems.Plugins) > 0 {
+		installs = append(installs, gems)
+	}
+	return append(installs, versioned...)
+}
+
+// Bytes returns the lock file with the plugins sorted by name.
+func (l *PluginLock) Bytes() ([]byte, error) {
+	sort.Slice(l.Plugins, func(i, j int) bool { return l.Plugins[i].Name < l.Plugins[j].Name })
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("# generated at staging, keep it with the app to install the same plugins on restage\n"), data...), nil
+}
@@ -0,0 +1,147 @@
+package config_test
+
+import (
+	conf "logstash/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Plugins", func() {
+	It("parses plugins with and without version", func() {
+		lc := conf.LogstashConfig{}
+		Expect(lc.Parse([]byte("plugins:\n- logstash-input-kafka\n- logstash-output-kafka: ^7.0\n"))).To(Succeed())
+		Expect(lc.Plugins).To(Equal([]conf.Plugin{{Name: "logstash-input-kafka"}, {Name: "logstash-output-kafka", Version: "^7.0"}}))
+
+		Expect(lc.Parse([]byte("plugins:\n- [a, b]\n"))).NotTo(Succeed())
+		Expect(conf.ParsePlugin(" logstash-output-kafka : 7.0.6 ")).To(Equal(conf.Plugin{Name: "logstash-output-kafka", Version: "7.0.6"}))
+	})
+
+	It("parses plugin files", func() {
+		name, version, ok := conf.ParsePluginFile("logstash-input-beats-5.0.6-java.gem")
+		Expect([]interface{}{name, version, ok}).To(Equal([]interface{}{"logstash-input-beats", "5.0.6", true}))
+		name, version, ok = conf.ParsePluginFile("logstash-input-kafka-connect-1.0.0.gem")
+		Expect([]interface{}{name, version, ok}).To(Equal([]interface{}{"logstash-input-kafka-connect", "1.0.0", true}))
+		name, version, ok = conf.ParsePluginFile("x-pack-6.0.0.zip")
+		Expect([]interface{}{name, version, ok}).To(Equal([]interface{}{"x-pack", "6.0.0", true}))
+		_, _, ok = conf.ParsePluginFile("README.md")
+		Expect(ok).To(BeFalse())
+	})
+
+	Describe("SelectPluginFile", func() {
+		files := []string{"logstash-input-kafka-connect-9.0.0.gem", "logstash-input-kafka-5.1.0.gem", "logstash-input-kafka-7.0.6.gem", "logstash-input-kafka-6.3.0.gem"}
+
+		It("matches the name exactly and selects the highest version", func() {
+			file, version := conf.SelectPluginFile("logstash-input-kafka", "", files)
+			Expect(file).To(Equal("logstash-input-kafka-7.0.6.gem"))
+			Expect(version).To(Equal("7.0.6"))
+		})
+
+		It("selects the highest version satisfying the constraint", func() {
+			file, _ := conf.SelectPluginFile("logstash-input-kafka", "^6.0", files)
+			Expect(file).To(Equal("logstash-input-kafka-6.3.0.gem"))
+			file, _ = conf.SelectPluginFile("logstash-input-kafka", ">= 5.0.0, < 6.0.0", files)
+			Expect(file).To(Equal("logstash-input-kafka-5.1.0.gem"))
+			file, _ = conf.SelectPluginFile("logstash-input-kafka", "8.0.0", files)
+			Expect(file).To(Equal(""))
+		})
+	})
+
+	It("detects exact versions", func() {
+		version, ok := conf.ExactVersion("7.0.6")
+		Expect(version).To(Equal("7.0.6"))
+		Expect(ok).To(BeTrue())
+		version, _ = conf.ExactVersion("= 7.0.6")
+		Expect(version).To(Equal("7.0.6"))
+		_, ok = conf.ExactVersion("7.0")
+		Expect(ok).To(BeFalse())
+		_, ok = conf.ExactVersion("^7.0.6")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("writes and reads the lock file", func() {
+		lock := conf.PluginLock{Logstash: "6.0.0", Plugins: []conf.LockedPlugin{
+			{Name: "logstash-output-kafka", Version: "7.0.6", Source: conf.PluginSourceRubygems},
+			{Name: "logstash-input-kafka", Version: "7.0.6", Source: conf.PluginSourceDefault, File: "logstash-input-kafka-7.0.6.gem", SHA256: "abc"},
+		}}
+		data, err := lock.Bytes()
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(HavePrefix("# generated at staging"))
+
+		read := conf.PluginLock{}
+		Expect(read.Parse(data)).To(Succeed())
+		Expect(read.Plugins[0].Name).To(Equal("logstash-input-kafka"))
+		locked, ok := read.Find("logstash-output-kafka")
+		Expect(ok).To(BeTrue())
+		Expect(locked.Version).To(Equal("7.0.6"))
+		_, ok = read.Find("logstash-filter-grok")
+		Expect(ok).To(BeFalse())
+	})
+
+	Describe("PluginInstalls", func() {
+		It("installs packs first, then all plugin files and all gems at once", func() {
+			xpack := conf.LockedPlugin{Name: "x-pack", Version: "6.0.0", Source: conf.PluginSourceXPack, File: "/deps/x-pack-6.0.0.zip"}
+			kafka := conf.LockedPlugin{Name: "logstash-input-kafka", Version: "7.0.6", Source: conf.PluginSourceDefault, File: "/deps/logstash-input-kafka-7.0.6.gem"}
+			beats := conf.LockedPlugin{Name: "logstash-input-beats", Version: "5.0.6", Source: conf.PluginSourceApp, File: "/app/plugins/logstash-input-beats-5.0.6-java.gem"}
+			exec := conf.LockedPlugin{Name: "logstash-output-exec", Source: conf.PluginSourceRubygems}
+			email := conf.LockedPlugin{Name: "logstash-output-email", Source: conf.PluginSourceRubygems}
+			http := conf.LockedPlugin{Name: "logstash-output-http", Version: "5.1.0", Source: conf.PluginSourceRubygems}
+
+			Expect(conf.PluginInstalls([]conf.LockedPlugin{exec, kafka, http, xpack, beats, email})).To(Equal([]conf.PluginInstall{
+				{Args: []string{"install", "file:///deps/x-pack-6.0.0.zip"}, Plugins: []conf.LockedPlugin{xpack}},
+				{Args: []string{"install", kafka.File, beats.File}, Plugins: []conf.LockedPlugin{kafka, beats}},
+				{Args: []string{"install", "logstash-output-exec", "logstash-output-email"}, Plugins: []conf.LockedPlugin{exec, email}},
+				{Args: []string{"install", "--version", "5.1.0", "logstash-output-http"}, Plugins: []conf.LockedPlugin{http}},
+			}))
+		})
+
+		It("has no runs without plugins", func() {
+			Expect(conf.PluginInstalls(nil)).To(BeEmpty())
+		})
+	})
+
+	Describe("Gemfile", func() {
+		gemfile := `source "https://rubygems.org"
+gem "logstash-core", :path => "./logstash-core"
+# gem "logstash-input-commented"
+gem "logstash-input-tcp"
+  gem 'logstash-output-kafka', "7.0.6"
+gem "logstash-codec-plain"
+`
+
+		It("lists the gems", func() {
+			Expect(conf.GemfilePlugins(gemfile)).To(Equal([]string{"logstash-core", "logstash-input-tcp", "logstash-output-kafka", "logstash-codec-plain"}))
+		})
+	})
+
+	Describe("CacheKey", func() {
+		lock := func(plugins ...conf.LockedPlugin) conf.PluginLock {
+			return conf.PluginLock{Logstash: "6.0.0", Plugins: plugins}
+		}
+		// the specs modify their own copies of the plugins
+		kafkaPlugin := conf.LockedPlugin{Name: "logstash-input-kafka", Version: "7.0.6", Source: conf.PluginSourceDefault, File: "logstash-input-kafka-7.0.6.gem", SHA256: "abc"}
+		httpPlugin := conf.LockedPlugin{Name: "logstash-output-http", Version: "5.1.0", Source: conf.PluginSourceRubygems}
+
+		It("is independent of the order and of the checksum of online installations", func() {
+			kafka, http := kafkaPlugin, httpPlugin
+			key := lock(kafka, http).CacheKey()
+			Expect(key).To(HaveLen(16))
+			Expect(lock(http, kafka).CacheKey()).To(Equal(key))
+
+			http.SHA256 = "def"
+			Expect(lock(kafka, http).CacheKey()).To(Equal(key))
+		})
+
+		It("changes with the Logstash version and the plugins", func() {
+			kafka, http := kafkaPlugin, httpPlugin
+			key := lock(kafka).CacheKey()
+			other := lock(kafka)
+			other.Logstash = "6.1.0"
+			Expect(other.CacheKey()).NotTo(Equal(key))
+
+			kafka.SHA256 = "def"
+			Expect(lock(kafka).CacheKey()).NotTo(Equal(key))
+			Expect(lock(kafka, http).CacheKey()).NotTo(Equal(key))
+		})
+	})
+})
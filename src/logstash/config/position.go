@@ -0,0 +1,109 @@
+package config
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Position is the location of a key in a yaml file (1-based).
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Line == 0 {
+		return ""
+	}
+	if p.Column == 0 {
+		return "line " + strconv.Itoa(p.Line)
+	}
+	return "line " + strconv.Itoa(p.Line) + ", column " + strconv.Itoa(p.Column)
+}
+
+var yamlKey = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s"'#:\[\]{},-][^#:]*?|-[^\s#:][^#:]*?)\s*:(\s+|$)`)
+
+type locatorFrame struct {
+	indent int
+	path   string
+	item   bool
+	items  int
+}
+
+// LocateKeys returns the position of every mapping key and sequence item of a
+// block style yaml document. The keys of the result are paths like
+// "curator.schedule" or "config-templates[1].name".
+// yaml.v2 does not expose positions, so this is a lightweight line scanner
+// which covers the subset of yaml used by the buildpack's config files.
+func LocateKeys(data []byte) map[string]Position {
+	positions := map[string]Position{}
+	stack := []*locatorFrame{}
+	blockIndent := -1
+
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		content := strings.TrimLeft(line, " ")
+		indent := len(line) - len(content)
+
+		// skip the content of block scalars (| and >)
+		if blockIndent >= 0 {
+			if content == "" || indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+
+		if content == "" || strings.HasPrefix(content, "#") || content == "---" || content == "..." {
+			continue
+		}
+
+		for content == "-" || strings.HasPrefix(content, "- ") {
+			for len(stack) > 0 {
+				top := stack[len(stack)-1]
+				if top.indent > indent || (top.indent == indent && top.item) {
+					stack = stack[:len(stack)-1]
+					continue
+				}
+				break
+			}
+
+			parent := &locatorFrame{indent: -1}
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			path := parent.path + "[" + strconv.Itoa(parent.items) + "]"
+			parent.items++
+			positions[path] = Position{Line: n + 1, Column: indent + 1}
+			stack = append(stack, &locatorFrame{indent: indent, path: path, item: true})
+
+			rest := strings.TrimLeft(content[1:], " ")
+			indent += len(content) - len(rest)
+			content = rest
+		}
+
+		match := yamlKey.FindStringSubmatch(content)
+		if match == nil {
+			continue
+		}
+
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		path := strings.Trim(match[1], `"'`)
+		if len(stack) > 0 {
+			path = stack[len(stack)-1].path + "." + path
+		}
+		positions[path] = Position{Line: n + 1, Column: indent + 1}
+
+		value := strings.TrimSpace(content[len(match[0]):])
+		if value == "" || strings.HasPrefix(value, "#") {
+			stack = append(stack, &locatorFrame{indent: indent, path: path})
+		} else if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			blockIndent = indent
+		}
+	}
+
+	return positions
+}
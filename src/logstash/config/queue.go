@@ -0,0 +1,110 @@
+package config
+
+import "strings"
+
+// Defaults of the queue section
+const (
+	DefaultQueueType = "memory"
+
+	// percentage of the disk quota which is never used for queues
+	QueueDiskMarginPercentage = 10
+
+	// percentage of the queue disk space used for dead letter queues, if
+	// neither max-bytes nor dead-letter-queue-max-bytes are set
+	DeadLetterQueuePercentage = 20
+
+	// minimal size of a persisted queue (queue.page_capacity of Logstash)
+	MinQueueMaxBytes = 64
+)
+
+// QueueSizes is the disk space in MB assigned to each persisted queue and to
+// each dead letter queue.
+type QueueSizes struct {
+	MaxBytes                int
+	DeadLetterQueueMaxBytes int
+	Queues                  int // number of persisted queues
+	DeadLetterQueues        int // number of dead letter queues
+	Available               int // disk space available for all queues
+	Required                int // disk space required by all queues
+}
+
+// Fits returns true if all queues fit into the available disk space.
+func (s QueueSizes) Fits() bool {
+	return s.Required <= s.Available
+}
+
+// PersistedQueues returns the number of pipelines with a persisted queue.
+func (c *LogstashConfig) PersistedQueues() int {
+	if len(c.Pipelines) == 0 {
+		if strings.EqualFold(c.Queue.Type, "persisted") {
+			return 1
+		}
+		return 0
+	}
+
+	queues := 0
+	for _, p := range c.Pipelines {
+		queueType := p.QueueType
+		if queueType == "" {
+			queueType = c.Queue.Type
+		}
+		if strings.EqualFold(queueType, "persisted") {
+			queues++
+		}
+	}
+	return queues
+}
+
+// DeadLetterQueues returns the number of pipelines with a dead letter queue.
+func (c *LogstashConfig) DeadLetterQueues() int {
+	if !c.Queue.DeadLetterQueue {
+		return 0
+	}
+	if len(c.Pipelines) == 0 {
+		return 1
+	}
+	return len(c.Pipelines)
+}
+
+// QueueSizes calculates the size of the queues. disk is the disk quota of the
+// app and used the disk space already used by the droplet (both in MB).
+// Sizes set in the Logstash file are kept, the others share the disk space
+// which is left after subtracting the used space and a safety margin.
+func (c *LogstashConfig) QueueSizes(disk, used int) QueueSizes {
+	s := QueueSizes{
+		MaxBytes:                c.Queue.MaxBytes,
+		DeadLetterQueueMaxBytes: c.Queue.DeadLetterQueueMaxBytes,
+		Queues:                  c.PersistedQueues(),
+		DeadLetterQueues:        c.DeadLetterQueues(),
+		Available:               disk - used - disk*QueueDiskMarginPercentage/100,
+	}
+	if s.Available < 0 {
+		s.Available = 0
+	}
+
+	if s.Queues > 0 && s.MaxBytes == 0 {
+		share := s.Available
+		if s.DeadLetterQueues > 0 {
+			if s.DeadLetterQueueMaxBytes == 0 {
+				share = s.Available * (100 - DeadLetterQueuePercentage) / 100
+			} else {
+				share = s.Available - s.DeadLetterQueueMaxBytes*s.DeadLetterQueues
+			}
+		}
+		s.MaxBytes = maxInt(share, 0) / s.Queues
+	}
+
+	if s.DeadLetterQueues > 0 && s.DeadLetterQueueMaxBytes == 0 {
+		s.DeadLetterQueueMaxBytes = maxInt(s.Available-s.MaxBytes*s.Queues, 0) / s.DeadLetterQueues
+	}
+
+	s.Required = s.MaxBytes*s.Queues + s.DeadLetterQueueMaxBytes*s.DeadLetterQueues
+	return s
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
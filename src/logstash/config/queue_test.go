@@ -0,0 +1,77 @@
+package config_test
+
+import (
+	conf "logstash/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("QueueSizes", func() {
+	var lc conf.LogstashConfig
+
+	BeforeEach(func() {
+		lc = conf.LogstashConfig{}
+		lc.ApplyDefaults()
+	})
+
+	Context("memory queue without dead letter queue", func() {
+		It("needs no disk space", func() {
+			sizes := lc.QueueSizes(1024, 400)
+			Expect(sizes.Queues).To(Equal(0))
+			Expect(sizes.DeadLetterQueues).To(Equal(0))
+			Expect(sizes.Required).To(Equal(0))
+			Expect(sizes.Fits()).To(BeTrue())
+		})
+	})
+
+	Context("persisted queue", func() {
+		BeforeEach(func() {
+			lc.Queue.Type = "persisted"
+		})
+
+		It("uses the disk quota without the droplet and the safety margin", func() {
+			sizes := lc.QueueSizes(2048, 500)
+			Expect(sizes.Available).To(Equal(2048 - 500 - 204))
+			Expect(sizes.MaxBytes).To(Equal(1344))
+			Expect(sizes.Fits()).To(BeTrue())
+		})
+
+		It("shares the disk space with the dead letter queue", func() {
+			lc.Queue.DeadLetterQueue = true
+			sizes := lc.QueueSizes(2048, 500)
+			Expect(sizes.MaxBytes).To(Equal(1075))
+			Expect(sizes.DeadLetterQueueMaxBytes).To(Equal(269))
+			Expect(sizes.Fits()).To(BeTrue())
+		})
+
+		It("keeps explicit sizes and reports if they do not fit", func() {
+			lc.Queue.MaxBytes = 2000
+			lc.Queue.DeadLetterQueue = true
+			sizes := lc.QueueSizes(2048, 500)
+			Expect(sizes.MaxBytes).To(Equal(2000))
+			Expect(sizes.DeadLetterQueueMaxBytes).To(Equal(0))
+			Expect(sizes.Fits()).To(BeFalse())
+		})
+	})
+
+	Context("multiple pipelines", func() {
+		BeforeEach(func() {
+			lc.Pipelines = []conf.Pipeline{
+				{ID: "a", QueueType: "persisted"},
+				{ID: "b"},
+				{ID: "c", QueueType: "persisted"},
+			}
+			lc.Queue.DeadLetterQueueMaxBytes = 100
+			lc.Queue.DeadLetterQueue = true
+		})
+
+		It("divides the disk space among the pipelines", func() {
+			sizes := lc.QueueSizes(2048, 500)
+			Expect(sizes.Queues).To(Equal(2))
+			Expect(sizes.DeadLetterQueues).To(Equal(3))
+			Expect(sizes.MaxBytes).To(Equal((1344 - 300) / 2))
+			Expect(sizes.Required).To(Equal(1044 + 300))
+		})
+	})
+})
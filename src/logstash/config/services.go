@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Modes of the service selection
+const (
+	ServiceSelectionSingle = "single"
+	ServiceSelectionAll    = "all"
+)
+
+var serviceSelectionModes = []string{ServiceSelectionSingle, ServiceSelectionAll}
+
+// ServiceSelection selects the service instances bound to the tagged default
+// templates (automatic mode). With mode "single" exactly one service instance
+// must be left after filtering by label, plan and name (a glob); with mode
+// "all" the template is rendered once per service instance.
+type ServiceSelection struct {
+	Mode  string `yaml:"mode"`
+	Label string `yaml:"label"`
+	Plan  string `yaml:"plan"`
+	Name  string `yaml:"name"`
+}
+
+// Matches returns true if the service instance fits label, plan and name of
+// the selection.
+func (s ServiceSelection) Matches(service VcapService) bool {
+	if s.Label != "" && !strings.EqualFold(s.Label, service.Label) {
+		return false
+	}
+	if s.Plan != "" && !strings.EqualFold(s.Plan, service.Plan) {
+		return false
+	}
+	if s.Name != "" {
+		if matched, err := path.Match(s.Name, service.Name); err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the matching service instances sorted by name.
+func (s ServiceSelection) Filter(services []VcapService) []VcapService {
+	result := []VcapService{}
+	for _, service := range services {
+		if s.Matches(service) {
+			result = append(result, service)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// IsAll returns true if templates are rendered once per service instance.
+func (s ServiceSelection) IsAll() bool {
+	return strings.EqualFold(s.Mode, ServiceSelectionAll)
+}
+
+// A Candidate is a service instance bound to the app, evaluated for a tagged
+// template. Reason explains why it has been accepted or rejected, Credentials
+// are detected for accepted candidates.
+type Candidate struct {
+	Service     VcapService
+	Accepted    bool
+	Reason      string
+	Credentials Credentials
+}
+
+func (c Candidate) String() string {
+	result := "rejected"
+	if c.Accepted {
+		result = "accepted"
+	}
+	return fmt.Sprintf("%s (label %s, plan %s): %s, %s", c.Service.Name, c.Service.Label, c.Service.Plan, result, c.Reason)
+}
+
+// Candidates evaluates all service instances bound to the app for the tagged
+// template t, sorted by name. A service instance is accepted if one of its
+// tags is a tag of the template, its credentials fit (see DetectCredentials)
+// and it matches the selection. User-provided service instances are accepted
+// without tag only if their credentials contain the host field of the alias of
+// the template, other user-provided service instances (e.g. log drains or
+// services of other templates with a generic uri) are rejected.
+func (s VcapServices) Candidates(t Template, tc *TemplatesConfig, selection ServiceSelection) []Candidate {
+	candidates := []Candidate{}
+	for label, services := range s {
+		for _, service := range services {
+			c := Candidate{Service: service}
+			alias := tc.AliasFor(t, label)
+			credentials, err := DetectCredentials(service.Credentials, alias)
+			switch {
+			case hasTag(service, t.Tags) && err != nil:
+				c.Reason = fmt.Sprintf("tagged with one of %s but %s", strings.Join(t.Tags, ", "), err.Error())
+			case hasTag(service, t.Tags):
+				c.Accepted, c.Reason = true, fmt.Sprintf("tagged with one of %s", strings.Join(t.Tags, ", "))
+			case label == "user-provided" && err == nil && credentials.HostField == alias.CredentialsHostField:
+				c.Accepted, c.Reason = true, fmt.Sprintf("user-provided with credential %q", credentials.HostField)
+			case label == "user-provided":
+				c.Reason = fmt.Sprintf("user-provided without a tag of %s and without credential %q", strings.Join(t.Tags, ", "), alias.CredentialsHostField)
+			default:
+				c.Reason = fmt.Sprintf("no tag of %s", strings.Join(t.Tags, ", "))
+			}
+			if c.Accepted && !selection.Matches(service) {
+				c.Accepted, c.Reason = false, "does not match service-selection"
+			}
+			if c.Accepted {
+				c.Credentials = credentials
+			}
+			candidates = append(candidates, c)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Service.Name < candidates[j].Service.Name })
+	return candidates
+}
+
+// Accepted returns the accepted candidates.
+func Accepted(candidates []Candidate) []Candidate {
+	result := []Candidate{}
+	for _, c := range candidates {
+		if c.Accepted {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func hasTag(service VcapService, tags []string) bool {
+	for _, st := range service.Tags {
+		for _, t := range tags {
+			if strings.EqualFold(t, st) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var unsafeIDChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// ID returns the id of the rendered template: the name of the template,
+// followed by the service instance name if the template is bound to one.
+// It is used for the file name and the plugin ids, which must be unique.
+func (t Template) ID() string {
+	if t.ServiceInstanceName == "" {
+		return t.Name
+	}
+	return t.Name + "-" + unsafeIDChars.ReplaceAllString(t.ServiceInstanceName, "_")
+}
@@ -0,0 +1,86 @@
+package config_test
+
+import (
+	conf "logstash/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ServiceSelection", func() {
+	services := []conf.VcapService{
+		{Name: "es-green", Label: "elasticsearch", Plan: "xsmall"},
+		{Name: "es-blue", Label: "elasticsearch", Plan: "small"},
+		{Name: "kibana", Label: "kibana", Plan: "small"},
+	}
+
+	names := func(services []conf.VcapService) []string {
+		result := []string{}
+		for _, s := range services {
+			result = append(result, s.Name)
+		}
+		return result
+	}
+
+	It("keeps all services without rules, sorted by name", func() {
+		Expect(names(conf.ServiceSelection{}.Filter(services))).To(Equal([]string{"es-blue", "es-green", "kibana"}))
+	})
+
+	It("filters by label, plan and name glob", func() {
+		Expect(names(conf.ServiceSelection{Label: "Elasticsearch"}.Filter(services))).To(Equal([]string{"es-blue", "es-green"}))
+		Expect(names(conf.ServiceSelection{Plan: "small"}.Filter(services))).To(Equal([]string{"es-blue", "kibana"}))
+		Expect(names(conf.ServiceSelection{Name: "es-*", Plan: "xsmall"}.Filter(services))).To(Equal([]string{"es-green"}))
+	})
+
+	It("validates mode and name", func() {
+		_, err := conf.ValidateLogstashFile([]byte("service-selection:\n  mode: some\n  name: \"es-[\"\n"), 0)
+		errs := err.(conf.ValidationErrors)
+		Expect(errs).To(HaveLen(2))
+		Expect(errs[0].Key).To(Equal("service-selection.mode"))
+		Expect(errs[1].Key).To(Equal("service-selection.name"))
+	})
+})
+
+var _ = Describe("Template.ID", func() {
+	It("is unique per service instance", func() {
+		Expect(conf.Template{Name: "cf-input-syslog"}.ID()).To(Equal("cf-input-syslog"))
+		Expect(conf.Template{Name: "cf-output-elasticsearch", ServiceInstanceName: "my es/1"}.ID()).To(Equal("cf-output-elasticsearch-my_es_1"))
+	})
+})
+
+var _ = Describe("VcapServices.Candidates", func() {
+	template := conf.Template{Name: "cf-output-elasticsearch", Tags: []string{"elasticsearch", "elastic"}}
+	tc := &conf.TemplatesConfig{Alias: conf.Alias{CredentialsHostField: "host", CredentialsUsernameField: "username", CredentialsPasswordField: "password"}}
+	services := conf.VcapServices{
+		"elasticsearch": {
+			{Name: "es", Label: "elasticsearch", Tags: []string{"Elastic"}, Credentials: map[string]interface{}{"uri": "https://user:secret@es:9200"}},
+			{Name: "es-untagged", Label: "elasticsearch"},
+		},
+		"user-provided": {
+			{Name: "log-drain", Label: "user-provided"},
+			{Name: "database", Label: "user-provided", Credentials: map[string]interface{}{"uri": "postgres://user:secret@db:5432/app"}},
+			{Name: "external-es", Label: "user-provided", Credentials: map[string]interface{}{"host": []interface{}{"https://es:9200"}}},
+		},
+	}
+
+	It("accepts tagged services and user-provided services with fitting credentials", func() {
+		candidates := services.Candidates(template, tc, conf.ServiceSelection{})
+		Expect(candidates).To(HaveLen(5))
+		Expect(candidates[0].String()).To(Equal(`database (label user-provided, plan ): rejected, user-provided without a tag of elasticsearch, elastic and without credential "host"`))
+		Expect(candidates[1].String()).To(Equal("es (label elasticsearch, plan ): accepted, tagged with one of elasticsearch, elastic"))
+		Expect(candidates[2].String()).To(Equal("es-untagged (label elasticsearch, plan ): rejected, no tag of elasticsearch, elastic"))
+		Expect(candidates[3].String()).To(Equal(`external-es (label user-provided, plan ): accepted, user-provided with credential "host"`))
+		Expect(candidates[4].String()).To(Equal(`log-drain (label user-provided, plan ): rejected, user-provided without a tag of elasticsearch, elastic and without credential "host"`))
+		Expect(conf.Accepted(candidates)).To(HaveLen(2))
+		Expect(candidates[1].Credentials).To(Equal(conf.Credentials{Shape: conf.CredentialsURI, HostField: "uri"}))
+		Expect(candidates[3].Credentials).To(Equal(conf.Credentials{Shape: conf.CredentialsHosts, HostField: "host"}))
+	})
+
+	It("rejects services not matching the selection", func() {
+		candidates := services.Candidates(template, tc, conf.ServiceSelection{Label: "elasticsearch"})
+		Expect(candidates[3].Accepted).To(BeFalse())
+		Expect(candidates[3].Reason).To(Equal("does not match service-selection"))
+		Expect(conf.Accepted(candidates)).To(HaveLen(1))
+		Expect(conf.Accepted(candidates)[0].Service).To(Equal(services["elasticsearch"][0]))
+	})
+})
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// logstashSettings lists the settings of logstash.yml by the Logstash version
+// which introduced them. Names ending with "." are prefixes.
+var logstashSettings = []struct {
+	since    string
+	settings []string
+}{
+	{"6.0.0", []string{
+		"node.name",
+		"path.data",
+		"pipeline.id",
+		"pipeline.workers",
+		"pipeline.output.workers",
+		"pipeline.batch.size",
+		"pipeline.batch.delay",
+		"pipeline.unsafe_shutdown",
+		"config.reload.automatic",
+		"config.reload.interval",
+		"config.debug",
+		"config.support_escapes",
+		"modules",
+		"queue.type",
+		"path.queue",
+		"queue.page_capacity",
+		"queue.max_events",
+		"queue.max_bytes",
+		"queue.checkpoint.acks",
+		"queue.checkpoint.writes",
+		"queue.checkpoint.interval",
+		"dead_letter_queue.enable",
+		"dead_letter_queue.max_bytes",
+		"path.dead_letter_queue",
+		"http.host",
+		"http.port",
+		"log.level",
+		"log.format",
+		"path.logs",
+		"path.plugins",
+		"xpack.",
+	}},
+	{"6.3.0", []string{
+		"pipeline.java_execution",
+	}},
+	{"6.4.0", []string{
+		"config.field_reference.parser",
+	}},
+}
+
+// buildpackSettings are managed by the buildpack and must not be defined in
+// the settings section.
+var buildpackSettings = []string{"path.config", "config.string", "config.test_and_exit", "path.settings"}
+
+// KnownSettings returns the settings of logstash.yml known by the given
+// Logstash version.
+func KnownSettings(version string) ([]string, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	known := []string{}
+	for _, s := range logstashSettings {
+		if !v.LessThan(semver.MustParse(s.since)) {
+			known = append(known, s.settings...)
+		}
+	}
+	return known, nil
+}
+
+// FlattenSettings returns the settings with nested maps flattened to dotted
+// keys, e.g. {http: {port: 9600}} to {"http.port": 9600}.
+func FlattenSettings(settings map[string]interface{}) map[string]interface{} {
+	flat := map[string]interface{}{}
+	flattenSettings("", settings, flat)
+	return flat
+}
+
+func flattenSettings(prefix string, value interface{}, flat map[string]interface{}) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		for k, v := range m {
+			flattenSettings(joinPath(prefix, k), v, flat)
+		}
+	case map[interface{}]interface{}:
+		for k, v := range m {
+			flattenSettings(joinPath(prefix, fmt.Sprint(k)), v, flat)
+		}
+	default:
+		flat[prefix] = value
+	}
+}
+
+// SortedKeys returns the keys of the settings in alphabetical order.
+func SortedKeys(settings map[string]interface{}) []string {
+	keys := []string{}
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ValidateSettings checks the keys of the settings section against the
+// settings known by the given Logstash version. positions are the positions of
+// the keys in the Logstash file (see LocateKeys), overrides the settings
+// overridden by environment variables.
+func ValidateSettings(lc *LogstashConfig, version string, positions map[string]Position, overrides []Override) error {
+	known, err := KnownSettings(version)
+	if err != nil {
+		return err
+	}
+
+	v := &validator{positions: map[string]Position{}, sources: map[string]string{}}
+	source := ""
+	for _, o := range overrides {
+		if o.Key == "settings" {
+			source = "environment variable " + o.Variable
+		}
+	}
+	for _, name := range SortedKeys(FlattenSettings(lc.Settings)) {
+		key := joinPath("settings", name)
+		if source != "" {
+			v.sources[key] = source
+		} else if p, ok := positions[key]; ok {
+			v.positions[key] = p
+		} else {
+			v.positions[key] = positions["settings"]
+		}
+
+		if containsFold(buildpackSettings, name) {
+			v.add(key, "setting %q is managed by the buildpack", name)
+			continue
+		}
+		if !isKnownSetting(known, name) {
+			if suggestion := closestName(name, known); suggestion != "" {
+				v.add(key, "unknown Logstash %s setting %q (did you mean %q?)", version, name, suggestion)
+			} else {
+				v.add(key, "unknown Logstash %s setting %q", version, name)
+			}
+		}
+	}
+
+	if len(v.errors) == 0 {
+		return nil
+	}
+	sort.Stable(v.errors)
+	return v.errors
+}
+
+func isKnownSetting(known []string, name string) bool {
+	for _, k := range known {
+		if k == name || (strings.HasSuffix(k, ".") && strings.HasPrefix(name, k)) {
+			return true
+		}
+	}
+	return false
+}
+
+// APIPort returns the port of the Logstash node API: the http.port setting
+// (the first port of a range) or DefaultAPIPort.
+func (c *LogstashConfig) APIPort() int {
+	switch port := FlattenSettings(c.Settings)["http.port"].(type) {
+	case int:
+		return port
+	case string:
+		if p, err := strconv.Atoi(strings.TrimSpace(strings.Split(port, "-")[0])); err == nil {
+			return p
+		}
+	}
+	return DefaultAPIPort
+}
+
+// Workers returns the number of pipeline workers of all pipelines. Pipelines
+// without workers use the pipeline.workers setting or, like Logstash, the
+// number of CPU cores.
+func (c *LogstashConfig) Workers(cpus int) int {
+	defaultWorkers := cpus
+	if workers, ok := FlattenSettings(c.Settings)["pipeline.workers"].(int); ok {
+		defaultWorkers = workers
+	}
+
+	if len(c.Pipelines) == 0 {
+		return defaultWorkers
+	}
+	workers := 0
+	for _, p := range c.Pipelines {
+		if p.Workers > 0 {
+			workers += p.Workers
+		} else {
+			workers += defaultWorkers
+		}
+	}
+	return workers
+}
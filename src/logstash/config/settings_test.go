@@ -0,0 +1,120 @@
+package config_test
+
+import (
+	conf "logstash/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateSettings", func() {
+	var (
+		data      string
+		version   string
+		overrides []conf.Override
+		err       error
+	)
+
+	BeforeEach(func() {
+		version = "6.0.0"
+		overrides = nil
+	})
+
+	JustBeforeEach(func() {
+		lc := conf.LogstashConfig{}
+		Expect(lc.Parse([]byte(data))).To(Succeed())
+		err = conf.ValidateSettings(&lc, version, conf.LocateKeys([]byte(data)), overrides)
+	})
+
+	Context("settings known by the Logstash version", func() {
+		BeforeEach(func() {
+			data = `settings:
+  http.host: 0.0.0.0
+  http:
+    port: 9600
+  pipeline.workers: 2
+  log.format: json
+  xpack.monitoring.enabled: false
+`
+		})
+
+		It("returns no error", func() {
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Context("unknown and buildpack managed settings", func() {
+		BeforeEach(func() {
+			data = `settings:
+  pipeline.worker: 2
+  path.config: /tmp
+  config.field_reference.parser: STRICT
+`
+		})
+
+		It("reports them with their position", func() {
+			errs := err.(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(3))
+			Expect(errs[0].Error()).To(Equal(`line 2, column 3: unknown Logstash 6.0.0 setting "pipeline.worker" (did you mean "pipeline.workers"?)`))
+			Expect(errs[1].Error()).To(Equal(`line 3, column 3: setting "path.config" is managed by the buildpack`))
+			Expect(errs[2].Key).To(Equal("settings.config.field_reference.parser"))
+		})
+
+		Context("with a later Logstash version", func() {
+			BeforeEach(func() {
+				version = "6.4.1"
+			})
+
+			It("knows the settings introduced since", func() {
+				Expect(err.(conf.ValidationErrors)).To(HaveLen(2))
+			})
+		})
+	})
+
+	Context("settings overridden by an environment variable", func() {
+		BeforeEach(func() {
+			data = "settings:\n  log.formats: json\n"
+			overrides = []conf.Override{{Key: "settings", Variable: "LS_BP_SETTINGS", Value: "log.formats=json"}}
+		})
+
+		It("reports the environment variable as source", func() {
+			errs := err.(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Source).To(Equal("environment variable LS_BP_SETTINGS"))
+		})
+	})
+})
+
+var _ = Describe("FlattenSettings", func() {
+	It("flattens nested maps to dotted keys", func() {
+		lc := conf.LogstashConfig{}
+		Expect(lc.Parse([]byte("settings:\n  http:\n    host: 0.0.0.0\n    port: 9600\n  log.level: warn\n"))).To(Succeed())
+		Expect(conf.FlattenSettings(lc.Settings)).To(Equal(map[string]interface{}{
+			"http.host": "0.0.0.0",
+			"http.port": 9600,
+			"log.level": "warn",
+		}))
+	})
+})
+
+var _ = Describe("APIPort", func() {
+	It("is the http.port setting or 9600", func() {
+		lc := conf.LogstashConfig{}
+		Expect(lc.APIPort()).To(Equal(9600))
+		lc.Settings = map[string]interface{}{"http": map[interface{}]interface{}{"port": 9601}}
+		Expect(lc.APIPort()).To(Equal(9601))
+		lc.Settings = map[string]interface{}{"http.port": "9700-9800"}
+		Expect(lc.APIPort()).To(Equal(9700))
+	})
+})
+
+var _ = Describe("Workers", func() {
+	It("sums the workers of all pipelines", func() {
+		lc := conf.LogstashConfig{}
+		Expect(lc.Workers(4)).To(Equal(4))
+		lc.Settings = map[string]interface{}{"pipeline.workers": 2}
+		Expect(lc.Workers(4)).To(Equal(2))
+		lc.Pipelines = []conf.Pipeline{{ID: "a", Workers: 3}, {ID: "b"}}
+		Expect(lc.Workers(4)).To(Equal(5))
+	})
+})
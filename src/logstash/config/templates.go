@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A TemplateParam is a parameter declared by a template. The value is passed
+// to the template as environment variable PARAM_<NAME> (see ParamVariable).
+type TemplateParam struct {
+	Name        string `yaml:"name"`
+	Default     string `yaml:"default"`
+	Required    bool   `yaml:"required"`
+	Description string `yaml:"description"`
+}
+
+var unsafeParamChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// ParamVariable returns the name of the environment variable of a template
+// parameter, e.g. PARAM_SSL_VERIFY for ssl-verify.
+func ParamVariable(name string) string {
+	return "PARAM_" + unsafeParamChars.ReplaceAllString(strings.ToUpper(name), "_")
+}
+
+// ResolveParams returns the values of all parameters declared by the template:
+// the given value or the default. It fails for unknown parameters and missing
+// required parameters.
+func (t Template) ResolveParams(params map[string]string) (map[string]string, error) {
+	declared := []string{}
+	values := map[string]string{}
+	problems := []string{}
+	for _, p := range t.Params {
+		declared = append(declared, p.Name)
+		if value, ok := params[p.Name]; ok {
+			values[p.Name] = value
+		} else if p.Required {
+			problems = append(problems, fmt.Sprintf("missing required parameter %q", p.Name))
+		} else {
+			values[p.Name] = p.Default
+		}
+	}
+
+	names := []string{}
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := values[name]; ok {
+			continue
+		}
+		if suggestion := closestName(name, declared); suggestion != "" {
+			problems = append(problems, fmt.Sprintf("unknown parameter %q (did you mean %q?)", name, suggestion))
+		} else {
+			problems = append(problems, fmt.Sprintf("unknown parameter %q", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return values, fmt.Errorf("template %s: %s", t.Name, strings.Join(problems, ", "))
+	}
+	return values, nil
+}
+
+// Merge merges the templates file o (e.g. of the app) into c: templates of o
+// replace the templates of c with the same name, other templates of o are
+// added. Aliases and label aliases of o override the fields they define.
+func (c *TemplatesConfig) Merge(o TemplatesConfig) {
+	c.Alias.merge(o.Alias)
+	for key := range o.Alias.set {
+		c.Alias.set.add(key)
+	}
+
+	for label, alias := range o.LabelAliases {
+		if c.LabelAliases == nil {
+			c.LabelAliases = map[string]Alias{}
+		}
+		merged := c.LabelAliases[label]
+		merged.merge(alias)
+		merged.set = keySet{}
+		for key := range c.LabelAliases[label].set {
+			merged.set.add(key)
+		}
+		for key := range alias.set {
+			merged.set.add(key)
+		}
+		c.LabelAliases[label] = merged
+	}
+
+	for _, t := range o.Templates {
+		replaced := false
+		for i := range c.Templates {
+			if c.Templates[i].Name == t.Name {
+				c.Templates[i] = t
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			c.Templates = append(c.Templates, t)
+		}
+	}
+}
+
+// Fallback returns the fallback template of the given type (e.g. output).
+func (c *TemplatesConfig) Fallback(templateType string) (Template, bool) {
+	for _, t := range c.Templates {
+		if t.IsFallback && t.Type == templateType {
+			return t, true
+		}
+	}
+	return Template{}, false
+}
+
+// SetDir sets the directory of the config files (<name>.conf) of all
+// templates.
+func (c *TemplatesConfig) SetDir(dir string) {
+	for i := range c.Templates {
+		c.Templates[i].Dir = dir
+	}
+}
@@ -0,0 +1,107 @@
+package config_test
+
+import (
+	conf "logstash/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TemplatesConfig.Merge", func() {
+	parse := func(data, dir string) conf.TemplatesConfig {
+		tc := conf.TemplatesConfig{}
+		Expect(tc.Parse([]byte(data))).To(Succeed())
+		tc.SetDir(dir)
+		return tc
+	}
+
+	It("overrides templates by name and adds new templates", func() {
+		tc := parse(`
+alias:
+  credentials-host-field: host
+templates:
+- name: cf-input-syslog
+  type: input
+  is-default: true
+- name: cf-output-elasticsearch
+  type: output
+  is-default: true
+  tags: [elasticsearch]
+`, "/bp/defaults/templates")
+		tc.Merge(parse(`
+alias:
+  credentials-username-field: user
+label-aliases:
+  my-es:
+    credentials-host-field: uri
+templates:
+- name: cf-output-elasticsearch
+  type: output
+  is-default: true
+  tags: [my-es]
+  groks: [my-grok]
+- name: org-filter-audit
+  type: filter
+  is-default: true
+`, "/app/templates"))
+		tc.Alias.ApplyDefaults()
+
+		Expect(tc.Templates).To(HaveLen(3))
+		Expect(tc.Templates[0].Dir).To(Equal("/bp/defaults/templates"))
+		Expect(tc.Templates[1].Name).To(Equal("cf-output-elasticsearch"))
+		Expect(tc.Templates[1].Tags).To(Equal([]string{"my-es"}))
+		Expect(tc.Templates[1].Groks).To(Equal([]string{"my-grok"}))
+		Expect(tc.Templates[1].Dir).To(Equal("/app/templates"))
+		Expect(tc.Templates[2].Name).To(Equal("org-filter-audit"))
+
+		Expect(tc.Alias.CredentialsHostField).To(Equal("host"))
+		Expect(tc.Alias.CredentialsUsernameField).To(Equal("user"))
+		Expect(tc.Alias.CredentialsPasswordField).To(Equal("password"))
+		Expect(tc.AliasFor(tc.Templates[1], "my-es").CredentialsHostField).To(Equal("uri"))
+	})
+})
+
+var _ = Describe("Template params", func() {
+	t := conf.Template{Name: "cf-output-elasticsearch", Params: []conf.TemplateParam{
+		{Name: "index", Default: "logstash-%{+YYYY.MM.dd}"},
+		{Name: "ssl_verify", Default: "true"},
+		{Name: "pipeline", Required: true},
+	}}
+
+	It("reads params of config templates from the Logstash file", func() {
+		lc := conf.LogstashConfig{}
+		Expect(lc.Parse([]byte("config-templates:\n- name: cf-output-elasticsearch\n  params:\n    index: audit\n    ssl_verify: false\n"))).To(Succeed())
+		Expect(lc.ConfigTemplates[0].Params).To(Equal(map[string]string{"index": "audit", "ssl_verify": "false"}))
+	})
+
+	It("resolves the values with defaults", func() {
+		values, err := t.ResolveParams(map[string]string{"index": "audit", "pipeline": "ingest"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values).To(Equal(map[string]string{"index": "audit", "ssl_verify": "true", "pipeline": "ingest"}))
+	})
+
+	It("fails for missing required and unknown params", func() {
+		_, err := t.ResolveParams(map[string]string{"indx": "audit", "codec": "json"})
+		Expect(err).To(MatchError(`template cf-output-elasticsearch: missing required parameter "pipeline", unknown parameter "codec", unknown parameter "indx" (did you mean "index"?)`))
+	})
+
+	It("passes the values as environment variables", func() {
+		Expect(conf.ParamVariable("ssl_verify")).To(Equal("PARAM_SSL_VERIFY"))
+		Expect(conf.ParamVariable("retry-on.conflict")).To(Equal("PARAM_RETRY_ON_CONFLICT"))
+	})
+})
+
+var _ = Describe("TemplatesConfig.Fallback", func() {
+	It("returns the fallback template of a type", func() {
+		tc := conf.TemplatesConfig{Templates: []conf.Template{
+			{Name: "cf-output-elasticsearch", Type: "output", IsDefault: true},
+			{Name: "cf-filter-fallback", Type: "filter", IsFallback: true},
+			{Name: "cf-output-stdout", Type: "output", IsFallback: true},
+		}}
+		t, found := tc.Fallback("output")
+		Expect(found).To(BeTrue())
+		Expect(t.Name).To(Equal("cf-output-stdout"))
+		_, found = tc.Fallback("input")
+		Expect(found).To(BeFalse())
+	})
+})
@@ -0,0 +1,440 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/robfig/cron"
+	"gopkg.in/yaml.v2"
+)
+
+// A ValidationError describes a single violation found in a config file or
+// in an environment variable overriding a setting (Source).
+type ValidationError struct {
+	Key      string
+	Position Position
+	Source   string
+	Message  string
+}
+
+func (e ValidationError) Error() string {
+	if e.Source != "" {
+		return fmt.Sprintf("%s: %s", e.Source, e.Message)
+	}
+	if e.Position.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Position, e.Message)
+}
+
+// ValidationErrors holds all violations found in a config file.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, v := range e {
+		lines[i] = v.Error()
+	}
+	return fmt.Sprintf("%d invalid setting(s) found:\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+func (e ValidationErrors) Len() int      { return len(e) }
+func (e ValidationErrors) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e ValidationErrors) Less(i, j int) bool {
+	if e[i].Position.Line != e[j].Position.Line {
+		return e[i].Position.Line < e[j].Position.Line
+	}
+	return e[i].Position.Column < e[j].Position.Column
+}
+
+var logLevels = []string{"info", "debug"}
+
+var queueTypes = []string{"memory", "persisted"}
+
+var pipelineID = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+var pluginName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+var yamlTypeErrorLine = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+var pluginType = reflect.TypeOf(Plugin{})
+
+// deprecatedKeys are settings of former versions of the Logstash file which
+// are still accepted with a warning, mapped to the setting replacing them
+// ("" if the setting is ignored).
+var deprecatedKeys = map[string]string{
+	"log-level":       "buildpack.log-level",
+	"curator.version": "",
+}
+
+type validator struct {
+	positions map[string]Position
+	sources   map[string]string
+	errors    ValidationErrors
+	warnings  ValidationErrors
+}
+
+func (v *validator) add(key string, format string, args ...interface{}) {
+	v.errors = append(v.errors, ValidationError{Key: key, Position: v.positions[key], Source: v.sources[key], Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warn(key string, format string, args ...interface{}) {
+	v.warnings = append(v.warnings, ValidationError{Key: key, Position: v.positions[key], Message: fmt.Sprintf(format, args...)})
+}
+
+// isValid returns true if the key is present (in the file or the
+// environment) and no violation has been reported for it so far.
+func (v *validator) isValid(key string) bool {
+	_, inFile := v.positions[key]
+	_, inEnv := v.sources[key]
+	if !inFile && !inEnv {
+		return false
+	}
+	for _, e := range v.errors {
+		if e.Key == key {
+			return false
+		}
+	}
+	return true
+}
+
+// keyAtLine returns the innermost key defined on the given line.
+func (v *validator) keyAtLine(line int) (string, Position) {
+	key, position := "", Position{Line: line}
+	for k, p := range v.positions {
+		if p.Line == line && (key == "" || p.Column > position.Column) {
+			key, position = k, p
+		}
+	}
+	return key, position
+}
+
+// ValidateLogstashFile checks the content of the Logstash file against the
+// schema of LogstashConfig: unknown settings, wrong value types and values out
+// of range are all reported at once. memLimit is the container memory limit in
+// MB, 0 if unknown. Deprecated settings are returned as warnings.
+func ValidateLogstashFile(data []byte, memLimit int) (ValidationErrors, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	v := &validator{positions: LocateKeys(data)}
+	v.checkKeys(document, reflect.TypeOf(LogstashConfig{}), "")
+
+	var lc LogstashConfig
+	if err := yaml.Unmarshal(data, &lc); err != nil {
+		typeError, ok := err.(*yaml.TypeError)
+		if !ok {
+			return nil, err
+		}
+		for _, msg := range typeError.Errors {
+			ve := ValidationError{Message: msg}
+			if m := yamlTypeErrorLine.FindStringSubmatch(msg); m != nil {
+				ve.Position.Line, _ = strconv.Atoi(m[1])
+				ve.Message = m[2]
+				ve.Key, ve.Position = v.keyAtLine(ve.Position.Line)
+			}
+			v.errors = append(v.errors, ve)
+		}
+	}
+
+	v.checkValues(&lc, memLimit)
+
+	sort.Stable(v.warnings)
+	if len(v.errors) == 0 {
+		return v.warnings, nil
+	}
+	sort.Stable(v.errors)
+	return v.warnings, v.errors
+}
+
+// ValidateOverrides checks the values of settings which have been overridden
+// by environment variables.
+func ValidateOverrides(lc *LogstashConfig, overrides []Override, memLimit int) error {
+	v := &validator{sources: map[string]string{}}
+	for _, o := range overrides {
+		v.sources[o.Key] = "environment variable " + o.Variable
+	}
+
+	v.checkValues(lc, memLimit)
+
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return v.errors
+}
+
+// checkValues checks the range of all present settings.
+func (v *validator) checkValues(lc *LogstashConfig, memLimit int) {
+	if v.isValid("heap-percentage") {
+		if lc.HeapPercentage < 1 || lc.HeapPercentage > 100 {
+			v.add("heap-percentage", "heap-percentage must be between 1 and 100, got %d", lc.HeapPercentage)
+		}
+	}
+
+	if v.isValid("reserved-memory") {
+		if lc.ReservedMemory < 0 {
+			v.add("reserved-memory", "reserved-memory must not be negative, got %d", lc.ReservedMemory)
+		} else if memLimit > 0 && lc.ReservedMemory >= memLimit {
+			v.add("reserved-memory", "reserved-memory (%dm) must be below the container memory limit (%dm)", lc.ReservedMemory, memLimit)
+		}
+	}
+
+	logLevelKey := "buildpack.log-level"
+	if _, ok := v.positions[logLevelKey]; !ok && v.sources[logLevelKey] == "" {
+		logLevelKey = "log-level" // deprecated alias
+	}
+	if v.isValid(logLevelKey) {
+		if !containsFold(logLevels, lc.Buildpack.LogLevel) {
+			v.add(logLevelKey, "unknown log-level %q, expected one of %s", lc.Buildpack.LogLevel, strings.Join(logLevels, ", "))
+		}
+	}
+
+	if v.isValid("curator.schedule") && lc.Curator.Schedule != "" {
+		if _, err := cron.Parse(lc.Curator.Schedule); err != nil {
+			v.add("curator.schedule", "curator.schedule %q is not a valid cron expression: %s", lc.Curator.Schedule, err.Error())
+		}
+	}
+
+	if v.isValid("health-check.input-port") && (lc.HealthCheck.InputPort < 1 || lc.HealthCheck.InputPort > 65535) {
+		v.add("health-check.input-port", "health-check.input-port must be between 1 and 65535, got %d", lc.HealthCheck.InputPort)
+	}
+
+	if v.isValid("service-selection.mode") && !containsFold(serviceSelectionModes, lc.ServiceSelection.Mode) {
+		v.add("service-selection.mode", "unknown service-selection.mode %q, expected one of %s", lc.ServiceSelection.Mode, strings.Join(serviceSelectionModes, ", "))
+	}
+	if v.isValid("service-selection.name") {
+		if _, err := path.Match(lc.ServiceSelection.Name, ""); err != nil {
+			v.add("service-selection.name", "service-selection.name %q is not a valid glob pattern", lc.ServiceSelection.Name)
+		}
+	}
+
+	if v.isValid("plugins") {
+		for i, p := range lc.Plugins {
+			if err := ValidateVersionConstraint(p.Version); err != nil {
+				v.add(fmt.Sprintf("plugins[%d]", i), "invalid version %q of plugin %s: %s", p.Version, p.Name, err.Error())
+			}
+		}
+	}
+
+	for _, list := range []struct {
+		key   string
+		names []string
+	}{{"remove-plugins", lc.RemovePlugins}, {"keep-plugins", lc.KeepPlugins}} {
+		for i, name := range list.names {
+			if !pluginName.MatchString(name) {
+				v.add(fmt.Sprintf("%s[%d]", list.key, i), "invalid plugin name %q in %s", name, list.key)
+			}
+		}
+	}
+	for i, name := range lc.RemovePlugins {
+		for _, p := range lc.Plugins {
+			if p.Name == name {
+				v.add(fmt.Sprintf("remove-plugins[%d]", i), "plugin %s is installed (plugins) and removed (remove-plugins)", name)
+			}
+		}
+		for _, kept := range lc.KeepPlugins {
+			if kept == name {
+				v.add(fmt.Sprintf("remove-plugins[%d]", i), "plugin %s is kept (keep-plugins) and removed (remove-plugins)", name)
+			}
+		}
+	}
+
+	if v.isValid("queue.type") && !containsFold(queueTypes, lc.Queue.Type) {
+		v.add("queue.type", "unknown queue.type %q, expected one of %s", lc.Queue.Type, strings.Join(queueTypes, ", "))
+	}
+	if v.isValid("queue.max-bytes") && lc.Queue.MaxBytes < MinQueueMaxBytes {
+		v.add("queue.max-bytes", "queue.max-bytes must be at least %d (MB), got %d", MinQueueMaxBytes, lc.Queue.MaxBytes)
+	}
+	if v.isValid("queue.dead-letter-queue-max-bytes") && lc.Queue.DeadLetterQueueMaxBytes < 1 {
+		v.add("queue.dead-letter-queue-max-bytes", "queue.dead-letter-queue-max-bytes must be at least 1 (MB), got %d", lc.Queue.DeadLetterQueueMaxBytes)
+	}
+
+	if v.isValid("config-templates") && len(lc.ConfigTemplates) > 0 && len(lc.Pipelines) > 0 {
+		v.add("config-templates", "config-templates can not be combined with pipelines, define them in pipelines[].config-templates instead")
+	}
+
+	ids := map[string]bool{}
+	for i, p := range lc.Pipelines {
+		key := fmt.Sprintf("pipelines[%d]", i)
+
+		if p.ID == "" {
+			v.add(key, "%s.id is required", key)
+		} else if !pipelineID.MatchString(p.ID) {
+			v.add(key+".id", "pipeline id %q may only contain letters, digits, '_', '.' and '-'", p.ID)
+		} else if ids[p.ID] {
+			v.add(key+".id", "pipeline id %q is not unique", p.ID)
+		}
+		ids[p.ID] = true
+
+		if v.isValid(key + ".config-dir") {
+			if filepath.IsAbs(p.ConfigDir) || strings.HasPrefix(filepath.Clean(p.ConfigDir), "..") {
+				v.add(key+".config-dir", "config-dir %q must be a path within the app", p.ConfigDir)
+			}
+		}
+		if v.isValid(key+".workers") && p.Workers < 1 {
+			v.add(key+".workers", "workers must be at least 1, got %d", p.Workers)
+		}
+		if v.isValid(key+".batch-size") && p.BatchSize < 1 {
+			v.add(key+".batch-size", "batch-size must be at least 1, got %d", p.BatchSize)
+		}
+		if v.isValid(key+".queue-type") && !containsFold(queueTypes, p.QueueType) {
+			v.add(key+".queue-type", "unknown queue-type %q, expected one of %s", p.QueueType, strings.Join(queueTypes, ", "))
+		}
+	}
+}
+
+// checkKeys walks the yaml document along the given type and reports every
+// key which has no matching yaml field and is not deprecated.
+func (v *validator) checkKeys(node interface{}, t reflect.Type, path string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == pluginType {
+		return // plugins are given as name or as name: version
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		mapping, ok := node.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		fields := yamlFields(t)
+		for key, value := range mapping {
+			name := fmt.Sprint(key)
+			keyPath := joinPath(path, name)
+			field, ok := fields[name]
+			if replacement, deprecated := deprecatedKeys[keyPath]; !ok && deprecated {
+				if replacement != "" {
+					v.warn(keyPath, "setting %q is deprecated, use %q instead", keyPath, replacement)
+				} else {
+					v.warn(keyPath, "setting %q is deprecated and ignored", keyPath)
+				}
+				continue
+			}
+			if !ok {
+				if suggestion := closestName(name, fieldNames(fields)); suggestion != "" {
+					v.add(keyPath, "unknown setting %q (did you mean %q?)", keyPath, joinPath(path, suggestion))
+				} else {
+					v.add(keyPath, "unknown setting %q", keyPath)
+				}
+				continue
+			}
+			v.checkKeys(value, field.Type, keyPath)
+		}
+
+	case reflect.Slice, reflect.Array:
+		items, ok := node.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			v.checkKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+
+	case reflect.Map:
+		mapping, ok := node.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		for key, value := range mapping {
+			v.checkKeys(value, t.Elem(), joinPath(path, fmt.Sprint(key)))
+		}
+	}
+}
+
+// yamlFields returns the fields of a struct type by their yaml name.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldNames(fields map[string]reflect.StructField) []string {
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	return names
+}
+
+// closestName returns the known name with the smallest edit distance to
+// name, if it is close enough to be a typo.
+func closestName(name string, names []string) string {
+	best := ""
+	bestDistance := len(name)/3 + 1
+	for _, candidate := range names {
+		if d := levenshtein(name, candidate); d < bestDistance || (d == bestDistance && candidate < best) {
+			best = candidate
+			bestDistance = d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
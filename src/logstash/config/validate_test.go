@@ -0,0 +1,292 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	conf "logstash/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateLogstashFile", func() {
+	var (
+		data     string
+		memLimit int
+		warnings conf.ValidationErrors
+		err      error
+	)
+
+	BeforeEach(func() {
+		memLimit = 0
+	})
+
+	JustBeforeEach(func() {
+		warnings, err = conf.ValidateLogstashFile([]byte(data), memLimit)
+	})
+
+	Context("a valid Logstash file", func() {
+		BeforeEach(func() {
+			data = `---
+version: 6.0.0
+heap-percentage: 75
+reserved-memory: 300
+config-templates:
+- name: cf-output-elasticsearch
+  service-instance-name: my-elasticsearch
+curator:
+  install: true
+  schedule: "0 5 2 * * *"
+buildpack:
+  log-level: Debug
+plugins:
+- logstash-input-kafka
+- logstash-output-kafka: "^7.0"
+`
+		})
+
+		It("returns no error", func() {
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Context("an empty Logstash file", func() {
+		BeforeEach(func() {
+			data = ""
+		})
+
+		It("returns no error", func() {
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Context("unknown settings", func() {
+		BeforeEach(func() {
+			data = `heap-percentag: 75
+config-templates:
+- name: cf-input-syslog
+  service-name: foo
+x-pack:
+  monitoring:
+    enabled: false
+`
+		})
+
+		It("reports every unknown key with its position", func() {
+			Expect(err).To(BeAssignableToTypeOf(conf.ValidationErrors{}))
+			errs := err.(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(3))
+			Expect(errs[0].Error()).To(Equal(`line 1, column 1: unknown setting "heap-percentag" (did you mean "heap-percentage"?)`))
+			Expect(errs[1].Error()).To(Equal(`line 4, column 3: unknown setting "config-templates[0].service-name"`))
+			Expect(errs[2].Error()).To(Equal(`line 5, column 1: unknown setting "x-pack"`))
+		})
+	})
+
+	Context("values out of range", func() {
+		BeforeEach(func() {
+			memLimit = 256
+			data = `heap-percentage: 0
+reserved-memory: 300
+curator:
+  schedule: "0 5 25 * * *"
+buildpack:
+  log-level: verbose
+`
+		})
+
+		It("reports all violations at once", func() {
+			errs := err.(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(4))
+			Expect(errs[0].Key).To(Equal("heap-percentage"))
+			Expect(errs[1].Key).To(Equal("reserved-memory"))
+			Expect(errs[1].Message).To(ContainSubstring("below the container memory limit (256m)"))
+			Expect(errs[2].Key).To(Equal("curator.schedule"))
+			Expect(errs[2].Position).To(Equal(conf.Position{Line: 4, Column: 3}))
+			Expect(errs[3].Key).To(Equal("buildpack.log-level"))
+		})
+	})
+
+	Context("invalid plugin versions", func() {
+		BeforeEach(func() {
+			data = `plugins:
+- logstash-input-kafka
+- logstash-output-kafka: "^seven"
+`
+		})
+
+		It("reports the plugin", func() {
+			errs := err.(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Key).To(Equal("plugins[1]"))
+			Expect(errs[0].Error()).To(HavePrefix("line 3, column 1: "))
+			Expect(errs[0].Message).To(ContainSubstring(`invalid version "^seven" of plugin logstash-output-kafka`))
+		})
+	})
+
+	Context("conflicting plugins to remove", func() {
+		BeforeEach(func() {
+			data = `plugins:
+- logstash-output-kafka: ^7.0
+keep-plugins:
+- logstash-input-tcp
+remove-plugins:
+- logstash-input-tcp
+- logstash-output-kafka
+- logstash output csv
+`
+		})
+
+		It("reports every conflicting plugin", func() {
+			errs := err.(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(3))
+			Expect(errs[0].Key).To(Equal("remove-plugins[0]"))
+			Expect(errs[0].Error()).To(Equal("line 6, column 1: plugin logstash-input-tcp is kept (keep-plugins) and removed (remove-plugins)"))
+			Expect(errs[1].Key).To(Equal("remove-plugins[1]"))
+			Expect(errs[1].Message).To(Equal("plugin logstash-output-kafka is installed (plugins) and removed (remove-plugins)"))
+			Expect(errs[2].Key).To(Equal("remove-plugins[2]"))
+			Expect(errs[2].Message).To(Equal(`invalid plugin name "logstash output csv" in remove-plugins`))
+		})
+	})
+
+	Context("invalid pipelines", func() {
+		BeforeEach(func() {
+			data = `pipelines:
+- id: main
+  workers: 0
+- id: main
+  queue-type: disk
+- config-dir: /etc
+  batch-size: 0
+`
+		})
+
+		It("reports every invalid pipeline setting", func() {
+			errs := err.(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(6))
+			Expect(errs[0].Key).To(Equal("pipelines[0].workers"))
+			Expect(errs[1].Error()).To(Equal(`line 4, column 3: pipeline id "main" is not unique`))
+			Expect(errs[2].Key).To(Equal("pipelines[1].queue-type"))
+			Expect(errs[3].Error()).To(Equal(`line 6, column 1: pipelines[2].id is required`))
+			Expect(errs[4].Key).To(Equal("pipelines[2].config-dir"))
+			Expect(errs[5].Key).To(Equal("pipelines[2].batch-size"))
+		})
+	})
+
+	Context("config templates with pipelines", func() {
+		BeforeEach(func() {
+			data = `config-templates:
+- name: cf-output-stdout
+pipelines:
+- id: main
+  config-templates:
+  - name: cf-input-syslog
+`
+		})
+
+		It("rejects the top-level config templates", func() {
+			errs := err.(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Error()).To(Equal("line 1, column 1: config-templates can not be combined with pipelines, define them in pipelines[].config-templates instead"))
+		})
+	})
+
+	Context("values of the wrong type", func() {
+		BeforeEach(func() {
+			data = `heap-percentage: lots
+config-check: maybe
+`
+		})
+
+		It("reports the yaml type errors with their line", func() {
+			errs := err.(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(2))
+			Expect(errs[0].Position.Line).To(Equal(1))
+			Expect(errs[1].Position.Line).To(Equal(2))
+		})
+	})
+
+	Context("deprecated settings", func() {
+		BeforeEach(func() {
+			data = `log-level: Debug
+curator:
+  install: true
+  version: ""
+`
+		})
+
+		It("accepts them with a warning", func() {
+			Expect(err).To(BeNil())
+			Expect(warnings).To(HaveLen(2))
+			Expect(warnings[0].Error()).To(Equal(`line 1, column 1: setting "log-level" is deprecated, use "buildpack.log-level" instead`))
+			Expect(warnings[1].Error()).To(Equal(`line 4, column 3: setting "curator.version" is deprecated and ignored`))
+		})
+	})
+
+	Context("an invalid deprecated log-level", func() {
+		BeforeEach(func() {
+			data = "log-level: Verbose\n"
+		})
+
+		It("reports the deprecated setting", func() {
+			errs := err.(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Error()).To(Equal(`line 1, column 1: unknown log-level "Verbose", expected one of info, debug`))
+		})
+	})
+
+	Context("the test fixtures", func() {
+		read := func(name string) string {
+			content, err := ioutil.ReadFile(filepath.Join("..", "..", "..", "test", name))
+			Expect(err).To(BeNil())
+			return string(content)
+		}
+
+		It("accepts the full Logstash file of former versions", func() {
+			_, err := conf.ValidateLogstashFile([]byte(read("Logstash_full")), 0)
+			Expect(err).To(BeNil())
+		})
+
+		It("rejects the removed x-pack settings", func() {
+			_, err := conf.ValidateLogstashFile([]byte(read("Logstash_xpack")), 0)
+			Expect(err).NotTo(BeNil())
+			errs := err.(conf.ValidationErrors)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Key).To(Equal("x-pack"))
+		})
+	})
+
+	Context("invalid yaml syntax", func() {
+		BeforeEach(func() {
+			data = "heap-percentage: 75\n  reserved-memory: 300\n"
+		})
+
+		It("returns the parser error", func() {
+			Expect(err).NotTo(BeNil())
+			Expect(err).NotTo(BeAssignableToTypeOf(conf.ValidationErrors{}))
+		})
+	})
+})
+
+var _ = Describe("LocateKeys", func() {
+	It("finds nested keys and sequence items", func() {
+		positions := conf.LocateKeys([]byte(`# comment
+plugins:
+- logstash-input-kafka
+config-templates:
+  - name: a
+    service-instance-name: b
+  - name: c
+curator:
+  schedule: |
+    not: a key
+  install: true
+`))
+		Expect(positions["plugins"]).To(Equal(conf.Position{Line: 2, Column: 1}))
+		Expect(positions["plugins[0]"]).To(Equal(conf.Position{Line: 3, Column: 1}))
+		Expect(positions["config-templates[0].service-instance-name"]).To(Equal(conf.Position{Line: 6, Column: 5}))
+		Expect(positions["config-templates[1].name"]).To(Equal(conf.Position{Line: 7, Column: 5}))
+		Expect(positions["curator.install"]).To(Equal(conf.Position{Line: 11, Column: 3}))
+		Expect(positions).NotTo(HaveKey("curator.not"))
+	})
+})
@@ -3,6 +3,7 @@ package main
 import (
 	"logstash/finalize"
 	_ "logstash/hooks"
+	"logstash/redact"
 	"os"
 	"time"
 
@@ -10,7 +11,9 @@ import (
 )
 
 func main() {
-	logger := libbuildpack.NewLogger(os.Stdout)
+	// credentials must not show up in the staging log
+	redactor := redact.New(append(redact.ServiceSecrets(os.Getenv("VCAP_SERVICES")), redact.EnvSecrets(os.Environ())...)...)
+	logger := libbuildpack.NewLogger(redactor.Writer(os.Stdout))
 
 	buildpackDir, err := libbuildpack.GetBuildpackDir()
 	if err != nil {
@@ -35,6 +38,7 @@ func main() {
 	if err != nil {
 		os.Exit(11)
 	}
+	gf.BuildpackDir = buildpackDir
 
 	if err := finalize.Run(gf); err != nil {
 		os.Exit(12)
@@ -6,7 +6,6 @@ import (
 	"golang"
 	"io"
 	"io/ioutil"
-	"logstash/util"
 	"os"
 	"path/filepath"
 )
@@ -24,9 +23,10 @@ type Stager interface {
 }
 
 type Finalizer struct {
-	Stager  Stager
-	Command Command
-	Log     *libbuildpack.Logger
+	Stager       Stager
+	Command      Command
+	Log          *libbuildpack.Logger
+	BuildpackDir string
 }
 
 func NewFinalizer(stager Stager, command Command, logger *libbuildpack.Logger) (*Finalizer, error) {
@@ -54,96 +54,47 @@ func Run(gf *Finalizer) error {
 		return err
 	}
 
+	if err := gf.InstallBinaries(); err != nil {
+		gf.Log.Error("Unable to install the launcher: %s", err.Error())
+		return err
+	}
+
 	if err := gf.CreateStartupEnvironment("/tmp"); err != nil {
-		gf.Log.Error("Unable to create startup scripts: %s", err.Error())
+		gf.Log.Error("Unable to create startup environment: %s", err.Error())
 		return err
 	}
 
 	return nil
 }
 
-func (gf *Finalizer) CreateStartupEnvironment(tempDir string) error {
-
-	//create start script
-
-	content := util.TrimLines(fmt.Sprintf(`
-				echo "--> STARTING UP ..."
-				MemLimits="$(echo ${VCAP_APPLICATION} | $JQ_HOME/jq '.limits.mem')"
-
-				echo "--> container memory limit = ${MemLimits}m"
-				if [ -n "$LS_BP_JAVA_OPTS" ] || [ -z "$MemLimits" ] || [ -z "$LS_BP_RESERVED_MEMORY"  ] || [ -z "$LS_BP_HEAP_PERCENTAGE" ] ; then
-					export LS_JAVA_OPTS=$LS_BP_JAVA_OPTS
-					echo "--> Using JAVA_OPTS=\"${LS_JAVA_OPTS}\" (user defined)"
-				else
-					HeapSize=$(( ($MemLimits - $LS_BP_RESERVED_MEMORY) / 100 * $LS_BP_HEAP_PERCENTAGE ))
-					export LS_JAVA_OPTS="-Xmx${HeapSize}m -Xms${HeapSize}m"
-					echo "--> Using JAVA_OPTS=\"${LS_JAVA_OPTS}\" (calculated)"
-				fi
-
-				echo "--> preparing runtime directories ..."
-				mkdir -p conf.d
-				mkdir -p grok-patterns
-				mkdir -p curator.d
-
-				if [ -d logstash.conf.d ] ; then
-					rm -rf logstash.conf.d
-				fi
-				mkdir -p logstash.conf.d
-
-				if [ -d curator.conf.d ] ; then
-					rm -rf curator.conf.d
-				fi
-				mkdir -p curator.conf.d
-
-				if [ -d ofelia ] ; then
-					rm -rf ofelia
-				fi
-				mkdir -p ofelia
-
-				echo "--> template processing ..."
-				$GTE_HOME/gte $HOME/conf.d $HOME/logstash.conf.d
-				$GTE_HOME/gte $LS_ROOT/conf.d $HOME/logstash.conf.d
-
-				#no template processing for grok-patterns
-
-				$GTE_HOME/gte $LS_ROOT/grok-patterns $HOME/grok-patterns
-
-				$GTE_HOME/gte $HOME/curator.d $HOME/curator.conf.d
-				$GTE_HOME/gte $LS_ROOT/curator.d $HOME/curator.conf.d
-
-				$GTE_HOME/gte $LS_ROOT/curator $HOME/bin
-				$GTE_HOME/gte $LS_ROOT/ofelia $HOME/ofelia
-
-				echo "--> STARTING LOGSTASH ..."
-				if [ -n "$LS_CMD_ARGS" ] ; then
-					echo "--> using LS_CMD_ARGS=\"$LS_CMD_ARGS\""
-				fi
-
-				if [ -n "$LS_DO_SLEEP" ] ; then
-					sleep 3600
-				fi
-
-				if [ -n "$LS_CURATOR_ENABLED" ] ; then
-					echo "--> running Curator once to create the Logstash index for today"
-					echo "    !! Curator is currently disabled !!"
-					#${HOME}/bin/curator.sh
-
-					echo "--> starting Ofelia for Curator in the background"
-					#$OFELIA_HOME/ofelia daemon --config ${HOME}/ofelia/schedule.ini 2>&1 &
-				fi
-
-				chmod +x $HOME/bin/*.sh
-				$LOGSTASH_HOME/bin/logstash -f logstash.conf.d $LS_CMD_ARGS
-				`))
-
-	err := ioutil.WriteFile(filepath.Join(gf.Stager.BuildDir(), "bin/run.sh"), []byte(content), 0755)
-	if err != nil {
-		gf.Log.Error("Unable to write start script: %s", err.Error())
-		return err
+// InstallBinaries copies the launcher and sidecar binaries of a packaged
+// buildpack to $LS_ROOT/bin. An unpackaged buildpack builds them there in
+// bin/finalize.
+func (gf *Finalizer) InstallBinaries() error {
+	for _, name := range []string{"launcher", "sidecar"} {
+		binary := filepath.Join(gf.Stager.DepDir(), "bin", name)
+		if exists, err := libbuildpack.FileExists(binary); err != nil {
+			return err
+		} else if exists {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+			return err
+		}
+		if err := libbuildpack.CopyFile(filepath.Join(gf.BuildpackDir, "bin", name), binary); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// CreateStartupEnvironment writes the release yml which starts the launcher
+// (see package launcher).
+func (gf *Finalizer) CreateStartupEnvironment(tempDir string) error {
+	startCommand := fmt.Sprintf("$DEPS_DIR/%s/bin/launcher", gf.Stager.DepsIdx())
 
-	//create release yml
-	err = ioutil.WriteFile(filepath.Join(tempDir, "buildpack-release-step.yml"), []byte(golang.ReleaseYAML("bin/run.sh")), 0644)
+	err := ioutil.WriteFile(filepath.Join(tempDir, "buildpack-release-step.yml"), []byte(golang.ReleaseYAML(startCommand)), 0644)
 	if err != nil {
 		gf.Log.Error("Unable to write release yml: %s", err.Error())
 		return err
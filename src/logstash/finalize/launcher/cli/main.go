@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"logstash/finalize/launcher"
+	"logstash/redact"
+)
+
+func main() {
+	// the JVM options and command line arguments may contain credentials
+	redactor := redact.New(append(redact.ServiceSecrets(os.Getenv("VCAP_SERVICES")), redact.EnvSecrets(os.Environ())...)...)
+	logger := launcher.NewLogger(redactor.Writer(os.Stdout), "launcher")
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	l, err := launcher.New(logger)
+	if err != nil {
+		logger.Error("unable to initialize the launcher", "error", err)
+		os.Exit(1)
+	}
+
+	code, err := l.Run(signals)
+	if err != nil {
+		logger.Error("unable to start Logstash", "error", err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
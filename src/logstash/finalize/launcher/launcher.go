@@ -0,0 +1,279 @@
+// Package launcher implements the start command of the Logstash app: it
+// prepares the runtime environment (JVM options, directories, templates) and
+// runs Logstash together with its auxiliary processes.
+package launcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+	"gopkg.in/yaml.v2"
+
+	conf "logstash/config"
+	"logstash/memory"
+	"logstash/render"
+)
+
+// A Launcher prepares the runtime environment and starts Logstash.
+type Launcher struct {
+	Home      string // $HOME, the app directory
+	Root      string // $LS_ROOT, the dependency directory of the buildpack
+	Log       *Logger
+	Pipelines []conf.Pipeline
+	Settings  map[string]interface{}
+}
+
+// New returns a Launcher for the app in $HOME with the pipelines of the
+// config.yml written at staging.
+func New(log *Logger) (*Launcher, error) {
+	l := &Launcher{
+		Home: os.Getenv("HOME"),
+		Root: os.Getenv("LS_ROOT"),
+		Log:  log,
+	}
+	if l.Home == "" || l.Root == "" {
+		return nil, fmt.Errorf("HOME and LS_ROOT must be set")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(l.Root, "config.yml"))
+	if err != nil {
+		return nil, err
+	}
+	config := struct {
+		Config struct {
+			Pipelines []conf.Pipeline        `yaml:"Pipelines"`
+			Settings  map[string]interface{} `yaml:"Settings"`
+		} `yaml:"config"`
+	}{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("unable to read config.yml: %s", err.Error())
+	}
+	l.Pipelines = config.Config.Pipelines
+	l.Settings = config.Config.Settings
+
+	return l, nil
+}
+
+// Run prepares the runtime environment and runs Logstash until it exits.
+// It returns the exit code of Logstash.
+func (l *Launcher) Run(signals <-chan os.Signal) (int, error) {
+	l.Log.Info("starting up")
+
+	javaOpts, calculated, err := l.JavaOpts()
+	if err != nil {
+		return 1, err
+	}
+	os.Setenv("LS_JAVA_OPTS", javaOpts)
+	if calculated {
+		l.Log.Info("using calculated JVM options", "LS_JAVA_OPTS", javaOpts)
+	} else {
+		l.Log.Info("using user defined JVM options", "LS_JAVA_OPTS", javaOpts)
+	}
+
+	healthCheck := os.Getenv("LS_HEALTH_CHECK_ENABLED") != ""
+	if healthCheck {
+		//the sidecar owns $PORT, the Logstash inputs (templates) bind to the inner input port
+		os.Setenv("LS_SIDECAR_PORT", os.Getenv("PORT"))
+		os.Setenv("PORT", os.Getenv("LS_HEALTH_CHECK_INPUT_PORT"))
+		l.Log.Info("health check enabled", "sidecar-port", os.Getenv("LS_SIDECAR_PORT"), "input-port", os.Getenv("PORT"))
+	}
+
+	if err := l.PrepareDirectories(); err != nil {
+		return 1, err
+	}
+	if err := l.ProcessTemplates(); err != nil {
+		return 1, err
+	}
+
+	if os.Getenv("LS_DO_SLEEP") != "" {
+		l.Log.Info("sleeping for an hour before starting Logstash")
+		select {
+		case <-time.After(time.Hour):
+		case sig := <-signals:
+			l.Log.Info("received signal while sleeping", "signal", sig)
+			return 0, nil
+		}
+	}
+
+	supervisor := NewSupervisor(l.Log)
+	if healthCheck {
+		supervisor.Add(Process{Name: "sidecar", Path: filepath.Join(l.Root, "bin", "sidecar"), Dir: l.Home, Restart: true})
+	}
+	if os.Getenv("LS_CURATOR_ENABLED") != "" {
+		job, err := l.CuratorJob()
+		if err != nil {
+			return 1, err
+		}
+		supervisor.AddJob(job)
+	}
+
+	if args := os.Getenv("LS_CMD_ARGS"); args != "" {
+		l.Log.Info("using additional command line arguments", "LS_CMD_ARGS", args)
+	}
+	return supervisor.Run(l.LogstashProcess(), signals)
+}
+
+// JavaOpts returns the JVM options of Logstash calculated from the container
+// memory limit (see package memory) with the options of LS_USER_JAVA_OPTS.
+// calculated is false if the memory limit or the memory settings are not
+// available, the options of LS_USER_JAVA_OPTS are used as they are then.
+func (l *Launcher) JavaOpts() (javaOpts string, calculated bool, err error) {
+	javaOpts = os.Getenv("LS_USER_JAVA_OPTS")
+
+	vcapApp := conf.VcapApp{}
+	if err := vcapApp.Parse([]byte(os.Getenv("VCAP_APPLICATION"))); err != nil || vcapApp.Limits == nil || vcapApp.Limits.Mem == 0 {
+		return javaOpts, false, nil
+	}
+	reserved, err := strconv.Atoi(os.Getenv("LS_RESERVED_MEMORY"))
+	if err != nil {
+		return javaOpts, false, nil
+	}
+	heapPercentage, err := strconv.Atoi(os.Getenv("LS_HEAP_PERCENTAGE"))
+	if err != nil {
+		return javaOpts, false, nil
+	}
+
+	lc := conf.LogstashConfig{Pipelines: l.Pipelines, Settings: l.Settings}
+	result, err := memory.Calculate(memory.Input{
+		TotalMemory:    vcapApp.Limits.Mem,
+		ReservedMemory: reserved,
+		HeapPercentage: heapPercentage,
+		Workers:        lc.Workers(runtime.NumCPU()),
+		JavaOpts:       javaOpts,
+	})
+	if err != nil {
+		return javaOpts, false, fmt.Errorf("unable to calculate the JVM memory: %s", err.Error())
+	}
+
+	fields := []interface{}{"limit", fmt.Sprintf("%dm", vcapApp.Limits.Mem), "heap", fmt.Sprintf("%dm", result.Heap),
+		"metaspace", fmt.Sprintf("%dm", result.Metaspace), "threads", result.Threads, "stacks", fmt.Sprintf("%dm", result.Stacks())}
+	if result.DirectMemory > 0 {
+		fields = append(fields, "direct-memory", fmt.Sprintf("%dm", result.DirectMemory))
+	}
+	l.Log.Info("JVM memory", fields...)
+	return result.String(), true, nil
+}
+
+// PrepareDirectories creates the directories of the app and resets the
+// directories which are generated by the template processing.
+func (l *Launcher) PrepareDirectories() error {
+	l.Log.Info("preparing runtime directories")
+
+	for _, dir := range []string{"conf.d", "grok-patterns", "curator.d", "bin"} {
+		if err := os.MkdirAll(filepath.Join(l.Home, dir), 0755); err != nil {
+			return err
+		}
+	}
+
+	generated := []string{"logstash.conf.d", "curator.conf.d"}
+	if len(l.Pipelines) > 0 {
+		generated = append(generated, "logstash.pipelines.d")
+	}
+	for _, dir := range generated {
+		if err := os.RemoveAll(filepath.Join(l.Home, dir)); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(l.Home, dir), 0755); err != nil {
+			return err
+		}
+	}
+	for _, p := range l.Pipelines {
+		if err := os.MkdirAll(filepath.Join(l.Home, "logstash.pipelines.d", p.ID), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Templates returns the template directories (source and destination) in the
+// order they are processed: the files of the app first, then the templates
+// installed by the buildpack.
+func (l *Launcher) Templates() [][2]string {
+	templates := [][2]string{
+		{filepath.Join(l.Home, "conf.d"), filepath.Join(l.Home, "logstash.conf.d")},
+		{filepath.Join(l.Root, "conf.d"), filepath.Join(l.Home, "logstash.conf.d")},
+	}
+	for _, p := range l.Pipelines {
+		dest := filepath.Join(l.Home, "logstash.pipelines.d", p.ID)
+		templates = append(templates,
+			[2]string{filepath.Join(l.Home, p.ConfigDir), dest},
+			[2]string{filepath.Join(l.Root, "pipelines", p.ID), dest})
+	}
+	return append(templates,
+		[2]string{filepath.Join(l.Root, "grok-patterns"), filepath.Join(l.Home, "grok-patterns")},
+		[2]string{filepath.Join(l.Home, "curator.d"), filepath.Join(l.Home, "curator.conf.d")},
+		[2]string{filepath.Join(l.Root, "curator.d"), filepath.Join(l.Home, "curator.conf.d")},
+		[2]string{filepath.Join(l.Root, "curator"), filepath.Join(l.Home, "bin")})
+}
+
+// ProcessTemplates renders the template directories (see Templates) with the
+// environment of the process. Source directories which do not exist are
+// skipped.
+func (l *Launcher) ProcessTemplates() error {
+	r := render.New([2]string{})
+
+	for _, t := range l.Templates() {
+		if _, err := os.Stat(t[0]); os.IsNotExist(err) {
+			continue
+		}
+
+		l.Log.Info("template processing", "src", t[0], "dest", t[1])
+		if err := r.RenderDir(t[0], t[1]); err != nil {
+			return fmt.Errorf("template processing of %s failed: %s", t[0], err.Error())
+		}
+	}
+
+	scripts, err := filepath.Glob(filepath.Join(l.Home, "bin", "*.sh"))
+	if err != nil {
+		return err
+	}
+	for _, script := range scripts {
+		if err := os.Chmod(script, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CuratorJob returns the job which runs Curator (bin/curator.sh) on the
+// schedule of LS_CURATOR_SCHEDULE.
+func (l *Launcher) CuratorJob() (Job, error) {
+	spec := os.Getenv("LS_CURATOR_SCHEDULE")
+	if spec == "" {
+		spec = conf.DefaultCuratorSchedule
+	}
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return Job{}, fmt.Errorf("invalid Curator schedule %q: %s", spec, err.Error())
+	}
+
+	l.Log.Info("scheduling Curator", "schedule", spec)
+	return Job{
+		Process:  Process{Name: "curator", Path: filepath.Join(l.Home, "bin", "curator.sh"), Dir: l.Home},
+		Schedule: schedule,
+	}, nil
+}
+
+// LogstashProcess returns the Logstash process: started with the settings of
+// $LS_ROOT/config and, for a single pipeline, the config of logstash.conf.d.
+func (l *Launcher) LogstashProcess() Process {
+	args := []string{"--path.settings", filepath.Join(l.Root, "config")}
+	if len(l.Pipelines) == 0 {
+		args = append(args, "-f", "logstash.conf.d")
+	}
+	args = append(args, strings.Fields(os.Getenv("LS_CMD_ARGS"))...)
+
+	return Process{
+		Name: "logstash",
+		Path: filepath.Join(os.Getenv("LOGSTASH_HOME"), "bin", "logstash"),
+		Args: args,
+		Dir:  l.Home,
+	}
+}
@@ -0,0 +1,175 @@
+package launcher_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	conf "logstash/config"
+	"logstash/finalize/launcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Launcher", func() {
+	var (
+		home   string
+		root   string
+		buffer *bytes.Buffer
+		l      *launcher.Launcher
+		env    map[string]string
+	)
+
+	setEnv := func(vars map[string]string) {
+		for k, v := range vars {
+			env[k] = os.Getenv(k)
+			os.Setenv(k, v)
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		home, err = ioutil.TempDir("", "launcher.home.")
+		Expect(err).To(BeNil())
+		root, err = ioutil.TempDir("", "launcher.root.")
+		Expect(err).To(BeNil())
+
+		buffer = new(bytes.Buffer)
+		env = map[string]string{}
+		l = &launcher.Launcher{Home: home, Root: root, Log: launcher.NewLogger(buffer, "launcher")}
+	})
+
+	AfterEach(func() {
+		for k, v := range env {
+			os.Setenv(k, v)
+		}
+		os.RemoveAll(home)
+		os.RemoveAll(root)
+	})
+
+	Describe("New", func() {
+		It("reads the pipelines from config.yml", func() {
+			Expect(ioutil.WriteFile(filepath.Join(root, "config.yml"), []byte("name: logstash\nconfig:\n  Pipelines:\n  - id: syslog\n    config-dir: pipelines/syslog\n"), 0644)).To(Succeed())
+			setEnv(map[string]string{"HOME": home, "LS_ROOT": root})
+
+			l, err := launcher.New(launcher.NewLogger(buffer, "launcher"))
+			Expect(err).To(BeNil())
+			Expect(l.Pipelines).To(Equal([]conf.Pipeline{{ID: "syslog", ConfigDir: "pipelines/syslog"}}))
+		})
+	})
+
+	Describe("JavaOpts", func() {
+		BeforeEach(func() {
+			setEnv(map[string]string{
+				"LS_USER_JAVA_OPTS":  "",
+				"VCAP_APPLICATION":   `{"limits": {"mem": 1024}}`,
+				"LS_RESERVED_MEMORY": "300",
+				"LS_HEAP_PERCENTAGE": "75",
+			})
+		})
+
+		It("calculates the memory from the memory limit", func() {
+			l.Pipelines = []conf.Pipeline{{ID: "syslog", Workers: 5}}
+			javaOpts, calculated, err := l.JavaOpts()
+			Expect(err).To(BeNil())
+			Expect(calculated).To(BeTrue())
+			Expect(javaOpts).To(Equal("-Xmx543m -Xms543m -Xss1024k -XX:MaxMetaspaceSize=128m"))
+		})
+
+		It("keeps the user defined options", func() {
+			setEnv(map[string]string{"LS_USER_JAVA_OPTS": "-Xmx256m -Dfoo=bar"})
+			javaOpts, _, err := l.JavaOpts()
+			Expect(err).To(BeNil())
+			Expect(javaOpts).To(HavePrefix("-Xmx256m -Xms256m "))
+			Expect(javaOpts).To(HaveSuffix(" -Dfoo=bar"))
+		})
+
+		It("fails if the memory does not suffice", func() {
+			setEnv(map[string]string{"LS_USER_JAVA_OPTS": "-Xmx1g"})
+			_, _, err := l.JavaOpts()
+			Expect(err).To(MatchError(ContainSubstring("does not fit")))
+		})
+
+		It("does not calculate without memory limit", func() {
+			setEnv(map[string]string{"VCAP_APPLICATION": ""})
+			_, calculated, err := l.JavaOpts()
+			Expect(err).To(BeNil())
+			Expect(calculated).To(BeFalse())
+		})
+	})
+
+	Describe("PrepareDirectories and ProcessTemplates", func() {
+		BeforeEach(func() {
+			setEnv(map[string]string{"LS_TEST_CODEC": "json_lines"})
+
+			Expect(os.MkdirAll(filepath.Join(home, "logstash.conf.d"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(home, "logstash.conf.d", "stale.conf"), []byte(""), 0644)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(root, "conf.d"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(root, "conf.d", "output.conf"), []byte("codec => {{ .Env.LS_TEST_CODEC }}"), 0644)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(root, "pipelines", "syslog"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(root, "pipelines", "syslog", "input.conf"), []byte(""), 0644)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(root, "curator"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(root, "curator", "curator.sh"), []byte(""), 0644)).To(Succeed())
+
+			l.Pipelines = []conf.Pipeline{{ID: "syslog", ConfigDir: "pipelines/syslog"}}
+		})
+
+		It("resets the generated directories and renders the templates", func() {
+			Expect(l.PrepareDirectories()).To(Succeed())
+			Expect(l.ProcessTemplates()).To(Succeed())
+
+			Expect(filepath.Join(home, "logstash.conf.d", "stale.conf")).NotTo(BeAnExistingFile())
+			Expect(ioutil.ReadFile(filepath.Join(home, "logstash.conf.d", "output.conf"))).To(Equal([]byte("codec => json_lines")))
+			Expect(filepath.Join(home, "logstash.pipelines.d", "syslog", "input.conf")).To(BeAnExistingFile())
+
+			info, err := os.Stat(filepath.Join(home, "bin", "curator.sh"))
+			Expect(err).To(BeNil())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+		})
+
+		It("fails with the file and line of an invalid template", func() {
+			Expect(os.MkdirAll(filepath.Join(home, "conf.d"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(home, "conf.d", "input.conf"), []byte("input {\n  {{ .Env.PORT | missing }}\n}"), 0644)).To(Succeed())
+			Expect(l.PrepareDirectories()).To(Succeed())
+			Expect(l.ProcessTemplates()).To(MatchError(ContainSubstring(filepath.Join(home, "conf.d", "input.conf") + ":2")))
+		})
+	})
+
+	Describe("CuratorJob", func() {
+		It("runs curator.sh on the schedule", func() {
+			setEnv(map[string]string{"LS_CURATOR_SCHEDULE": "0 5 2 * * *"})
+			job, err := l.CuratorJob()
+			Expect(err).To(BeNil())
+			Expect(job.Process.Path).To(Equal(filepath.Join(home, "bin", "curator.sh")))
+			now := time.Date(2017, 11, 20, 8, 0, 0, 0, time.UTC)
+			Expect(job.Schedule.Next(now)).To(Equal(time.Date(2017, 11, 21, 2, 5, 0, 0, time.UTC)))
+		})
+
+		It("fails on an invalid schedule", func() {
+			setEnv(map[string]string{"LS_CURATOR_SCHEDULE": "never"})
+			_, err := l.CuratorJob()
+			Expect(err).To(MatchError(ContainSubstring(`invalid Curator schedule "never"`)))
+		})
+	})
+
+	Describe("LogstashProcess", func() {
+		BeforeEach(func() {
+			setEnv(map[string]string{"LOGSTASH_HOME": "/deps/0/logstash", "LS_CMD_ARGS": "--log.level=warn  --config.reload.automatic"})
+		})
+
+		It("starts a single pipeline with -f", func() {
+			p := l.LogstashProcess()
+			Expect(p.Path).To(Equal("/deps/0/logstash/bin/logstash"))
+			Expect(p.Args).To(Equal([]string{"--path.settings", filepath.Join(root, "config"), "-f", "logstash.conf.d", "--log.level=warn", "--config.reload.automatic"}))
+			Expect(p.Dir).To(Equal(home))
+		})
+
+		It("starts multiple pipelines with pipelines.yml", func() {
+			l.Pipelines = []conf.Pipeline{{ID: "syslog"}}
+			Expect(l.LogstashProcess().Args).To(Equal([]string{"--path.settings", filepath.Join(root, "config"), "--log.level=warn", "--config.reload.automatic"}))
+		})
+	})
+})
@@ -0,0 +1,64 @@
+package launcher
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Logger writes structured log lines in logfmt, e.g.
+//
+//	time=2017-11-20T08:00:00Z level=info component=launcher msg="starting Logstash" pid=42
+type Logger struct {
+	out       io.Writer
+	component string
+	mu        sync.Mutex
+	now       func() time.Time
+}
+
+func NewLogger(out io.Writer, component string) *Logger {
+	return &Logger{out: out, component: component, now: time.Now}
+}
+
+// Info logs msg with the given fields (alternating keys and values).
+func (l *Logger) Info(msg string, fields ...interface{}) {
+	l.log("info", msg, fields)
+}
+
+func (l *Logger) Warning(msg string, fields ...interface{}) {
+	l.log("warning", msg, fields)
+}
+
+func (l *Logger) Error(msg string, fields ...interface{}) {
+	l.log("error", msg, fields)
+}
+
+func (l *Logger) log(level string, msg string, fields []interface{}) {
+	line := []string{
+		"time=" + l.now().UTC().Format(time.RFC3339),
+		"level=" + level,
+		"component=" + l.component,
+		"msg=" + quote(msg),
+	}
+	for i := 0; i < len(fields); i += 2 {
+		value := "<missing>"
+		if i+1 < len(fields) {
+			value = fmt.Sprint(fields[i+1])
+		}
+		line = append(line, fmt.Sprintf("%v=%s", fields[i], quote(value)))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.out, strings.Join(line, " "))
+}
+
+func quote(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		return strconv.Quote(value)
+	}
+	return value
+}
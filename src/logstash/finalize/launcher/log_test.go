@@ -0,0 +1,29 @@
+package launcher_test
+
+import (
+	"bytes"
+
+	"logstash/finalize/launcher"
+	"logstash/redact"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logger", func() {
+	It("writes logfmt lines", func() {
+		buffer := new(bytes.Buffer)
+		launcher.NewLogger(buffer, "launcher").Info("process started", "process", "logstash", "pid", 42, "args", "")
+		Expect(buffer.String()).To(MatchRegexp(`^time=\S+ level=info component=launcher msg="process started" process=logstash pid=42 args=""\n$`))
+	})
+
+	It("masks secrets of the JVM options and command line arguments", func() {
+		buffer := new(bytes.Buffer)
+		log := launcher.NewLogger(redact.New("s3cr3t-pw").Writer(buffer), "launcher")
+		log.Info("using user defined JVM options", "LS_JAVA_OPTS", "-Xmx1g -Djavax.net.ssl.trustStorePassword=changeit")
+		log.Info("using additional command line arguments", "LS_CMD_ARGS", "--path.settings /tmp --api.auth.basic.password s3cr3t-pw")
+		Expect(buffer.String()).To(ContainSubstring(`LS_JAVA_OPTS="-Xmx1g -Djavax.net.ssl.trustStorePassword=[REDACTED]"`))
+		Expect(buffer.String()).To(ContainSubstring(`--api.auth.basic.password [REDACTED]"`))
+		Expect(buffer.String()).NotTo(ContainSubstring("changeit"))
+	})
+})
@@ -0,0 +1,89 @@
+package launcher
+
+import (
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// A Job is a process run by the Supervisor at the activation times of its
+// schedule, e.g. Curator. A run is skipped while the previous run is still
+// running.
+type Job struct {
+	Process  Process
+	Schedule cron.Schedule
+}
+
+// AddJob adds a scheduled job.
+func (s *Supervisor) AddJob(job Job) {
+	s.Jobs = append(s.Jobs, job)
+}
+
+func (s *Supervisor) schedule(job Job) {
+	defer s.wg.Done()
+
+	finished := make(chan struct{}, 1)
+	running := false
+
+	for {
+		next := job.Schedule.Next(time.Now())
+		s.Log.Info("next scheduled run", "process", job.Process.Name, "at", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(next.Sub(time.Now()))
+	wait:
+		for {
+			select {
+			case <-s.stop:
+				timer.Stop()
+				if running {
+					<-finished
+				}
+				return
+			case <-finished:
+				running = false
+			case <-timer.C:
+				break wait
+			}
+		}
+
+		// the previous run may have finished at the same time the timer fired
+		select {
+		case <-finished:
+			running = false
+		default:
+		}
+
+		if running {
+			s.Log.Warning("skipping scheduled run, the previous run is still running", "process", job.Process.Name)
+			continue
+		}
+
+		running = true
+		go func() {
+			s.run(job.Process)
+			finished <- struct{}{}
+		}()
+	}
+}
+
+// run runs a process of a job once and logs its exit status.
+func (s *Supervisor) run(p Process) {
+	cmd := p.command()
+	if err := s.start(p.Name, cmd); err == errStopping {
+		return
+	} else if err != nil {
+		s.Log.Error("unable to start scheduled run", "process", p.Name, "error", err)
+		return
+	}
+
+	started := time.Now()
+	code := exitCode(cmd.Wait())
+	s.forget(p.Name)
+
+	duration := time.Since(started).Round(time.Millisecond)
+	if code == 0 {
+		s.Log.Info("scheduled run finished", "process", p.Name, "exit-code", code, "duration", duration)
+	} else {
+		s.Log.Error("scheduled run failed", "process", p.Name, "exit-code", code, "duration", duration)
+	}
+}
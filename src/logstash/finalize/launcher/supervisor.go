@@ -0,0 +1,223 @@
+package launcher
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var errStopping = errors.New("supervisor is stopping")
+
+// A Process is started and supervised by the Supervisor.
+type Process struct {
+	Name    string
+	Path    string
+	Args    []string
+	Dir     string
+	Restart bool // restart the process whenever it exits
+}
+
+func (p Process) command() *exec.Cmd {
+	cmd := exec.Command(p.Path, p.Args...)
+	cmd.Dir = p.Dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// A Supervisor runs a main process (Logstash) together with auxiliary
+// processes (e.g. the sidecar) and scheduled jobs (e.g. Curator). Signals are
+// forwarded to all processes.
+// Auxiliary processes are restarted when they exit; when the main process
+// exits, the auxiliary processes are stopped.
+type Supervisor struct {
+	Log          *Logger
+	Processes    []Process
+	Jobs         []Job
+	RestartDelay time.Duration
+	StopTimeout  time.Duration
+
+	mu       sync.Mutex
+	running  map[string]*exec.Cmd
+	stopping bool
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewSupervisor(log *Logger) *Supervisor {
+	return &Supervisor{
+		Log:          log,
+		RestartDelay: 5 * time.Second,
+		StopTimeout:  10 * time.Second,
+	}
+}
+
+// Add adds an auxiliary process.
+func (s *Supervisor) Add(p Process) {
+	s.Processes = append(s.Processes, p)
+}
+
+// Run starts the auxiliary processes and the main process and waits until the
+// main process exits. Signals received on the channel are forwarded to all
+// running processes. It returns the exit code of the main process.
+func (s *Supervisor) Run(main Process, signals <-chan os.Signal) (int, error) {
+	s.running = map[string]*exec.Cmd{}
+	s.stop = make(chan struct{})
+
+	for _, p := range s.Processes {
+		s.wg.Add(1)
+		go s.supervise(p)
+	}
+	for _, job := range s.Jobs {
+		s.wg.Add(1)
+		go s.schedule(job)
+	}
+
+	cmd := main.command()
+	if err := s.start(main.Name, cmd); err != nil {
+		s.stopAll()
+		return 1, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	for {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGTERM || sig == os.Interrupt {
+				// the auxiliary processes terminate as well, they must not be restarted
+				s.markStopping()
+			}
+			s.Log.Info("forwarding signal", "signal", sig)
+			s.signalAll(sig)
+
+		case err := <-done:
+			code := exitCode(err)
+			s.forget(main.Name)
+			s.Log.Info("process exited", "process", main.Name, "exit-code", code)
+			s.stopAll()
+			return code, nil
+		}
+	}
+}
+
+func (s *Supervisor) supervise(p Process) {
+	defer s.wg.Done()
+
+	for {
+		cmd := p.command()
+		if err := s.start(p.Name, cmd); err == errStopping {
+			return
+		} else if err != nil {
+			s.Log.Error("unable to start process", "process", p.Name, "error", err)
+		} else {
+			code := exitCode(cmd.Wait())
+			s.forget(p.Name)
+			if s.isStopping() {
+				s.Log.Info("process stopped", "process", p.Name, "exit-code", code)
+				return
+			}
+			s.Log.Warning("process exited", "process", p.Name, "exit-code", code)
+		}
+
+		if !p.Restart {
+			return
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(s.RestartDelay):
+			s.Log.Info("restarting process", "process", p.Name)
+		}
+	}
+}
+
+func (s *Supervisor) start(name string, cmd *exec.Cmd) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopping {
+		return errStopping
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	s.running[name] = cmd
+	s.Log.Info("process started", "process", name, "pid", cmd.Process.Pid)
+	return nil
+}
+
+func (s *Supervisor) forget(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, name)
+}
+
+// markStopping prevents (re)starts of processes and jobs.
+func (s *Supervisor) markStopping() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopping {
+		s.stopping = true
+		close(s.stop)
+	}
+}
+
+func (s *Supervisor) isStopping() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopping
+}
+
+func (s *Supervisor) signalAll(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, cmd := range s.running {
+		if err := cmd.Process.Signal(sig); err != nil {
+			s.Log.Warning("unable to signal process", "process", name, "signal", sig, "error", err)
+		}
+	}
+}
+
+// stopAll terminates the auxiliary processes and running jobs, they are killed if they do not
+// exit within StopTimeout.
+func (s *Supervisor) stopAll() {
+	s.markStopping()
+	s.signalAll(syscall.SIGTERM)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(s.StopTimeout):
+		s.signalAll(os.Kill)
+		<-stopped
+	}
+}
+
+// exitCode returns the exit code of a process from the error of Wait, 128 +
+// signal number if the process has been terminated by a signal.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			if status.Signaled() {
+				return 128 + int(status.Signal())
+			}
+			return status.ExitStatus()
+		}
+	}
+	return 1
+}
@@ -0,0 +1,128 @@
+package launcher_test
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"time"
+
+	"logstash/finalize/launcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Supervisor", func() {
+	var (
+		buffer  *bytes.Buffer
+		s       *launcher.Supervisor
+		signals chan os.Signal
+	)
+
+	shell := func(name string, script string, restart bool) launcher.Process {
+		return launcher.Process{Name: name, Path: "/bin/sh", Args: []string{"-c", script}, Restart: restart}
+	}
+
+	BeforeEach(func() {
+		buffer = new(bytes.Buffer)
+		s = launcher.NewSupervisor(launcher.NewLogger(buffer, "launcher"))
+		s.RestartDelay = 10 * time.Millisecond
+		s.StopTimeout = time.Second
+		signals = make(chan os.Signal, 1)
+	})
+
+	It("returns the exit code of the main process", func() {
+		code, err := s.Run(shell("logstash", "exit 3", false), signals)
+		Expect(err).To(BeNil())
+		Expect(code).To(Equal(3))
+		Expect(buffer.String()).To(ContainSubstring(`msg="process exited" process=logstash exit-code=3`))
+	})
+
+	It("forwards signals to the main process", func() {
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			signals <- syscall.SIGTERM
+		}()
+		code, err := s.Run(shell("logstash", "trap 'exit 0' TERM; while true; do sleep 0.05; done", false), signals)
+		Expect(err).To(BeNil())
+		Expect(code).To(Equal(0))
+		Expect(buffer.String()).To(ContainSubstring(`msg="forwarding signal" signal=terminated`))
+	})
+
+	It("restarts auxiliary processes and stops them with the main process", func() {
+		s.Add(shell("sidecar", "exit 1", true))
+		code, err := s.Run(shell("logstash", "sleep 0.2", false), signals)
+		Expect(err).To(BeNil())
+		Expect(code).To(Equal(0))
+		Expect(buffer.String()).To(ContainSubstring(`msg="restarting process" process=sidecar`))
+	})
+
+	It("does not restart auxiliary processes after SIGTERM", func() {
+		s.Add(shell("sidecar", "while true; do sleep 0.05; done", true))
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			signals <- syscall.SIGTERM
+		}()
+		// Logstash takes a while to shut down, the sidecar exits at once
+		code, err := s.Run(shell("logstash", "trap 'sleep 0.2; exit 0' TERM; while true; do sleep 0.05; done", false), signals)
+		Expect(err).To(BeNil())
+		Expect(code).To(Equal(0))
+		Expect(buffer.String()).To(ContainSubstring(`msg="process stopped" process=sidecar`))
+		Expect(buffer.String()).NotTo(ContainSubstring("restarting process"))
+	})
+
+	It("fails if the main process can not be started", func() {
+		_, err := s.Run(launcher.Process{Name: "logstash", Path: "/does/not/exist"}, signals)
+		Expect(err).NotTo(BeNil())
+	})
+})
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+var _ = Describe("Supervisor with scheduled jobs", func() {
+	var (
+		buffer *bytes.Buffer
+		s      *launcher.Supervisor
+	)
+
+	BeforeEach(func() {
+		buffer = new(bytes.Buffer)
+		s = launcher.NewSupervisor(launcher.NewLogger(buffer, "launcher"))
+		s.StopTimeout = time.Second
+	})
+
+	It("runs the job on schedule and logs its exit status", func() {
+		s.AddJob(launcher.Job{
+			Process:  launcher.Process{Name: "curator", Path: "/bin/sh", Args: []string{"-c", "exit 2"}},
+			Schedule: every(50 * time.Millisecond),
+		})
+		_, err := s.Run(launcher.Process{Name: "logstash", Path: "/bin/sh", Args: []string{"-c", "sleep 0.3"}}, make(chan os.Signal))
+		Expect(err).To(BeNil())
+		Expect(buffer.String()).To(ContainSubstring(`msg="scheduled run failed" process=curator exit-code=2`))
+	})
+
+	It("does not overlap runs", func() {
+		s.AddJob(launcher.Job{
+			Process:  launcher.Process{Name: "curator", Path: "/bin/sh", Args: []string{"-c", "sleep 0.2"}},
+			Schedule: every(50 * time.Millisecond),
+		})
+		_, err := s.Run(launcher.Process{Name: "logstash", Path: "/bin/sh", Args: []string{"-c", "sleep 0.5"}}, make(chan os.Signal))
+		Expect(err).To(BeNil())
+		Expect(buffer.String()).To(ContainSubstring(`msg="skipping scheduled run, the previous run is still running" process=curator`))
+		Expect(buffer.String()).To(ContainSubstring(`msg="scheduled run finished" process=curator exit-code=0`))
+	})
+	It("does not skip runs which follow a finished run", func() {
+		s.AddJob(launcher.Job{
+			Process:  launcher.Process{Name: "curator", Path: "/bin/sh", Args: []string{"-c", "exit 0"}},
+			Schedule: every(20 * time.Millisecond),
+		})
+		_, err := s.Run(launcher.Process{Name: "logstash", Path: "/bin/sh", Args: []string{"-c", "sleep 0.3"}}, make(chan os.Signal))
+		Expect(err).To(BeNil())
+		Expect(buffer.String()).To(ContainSubstring(`msg="scheduled run finished" process=curator exit-code=0`))
+		Expect(buffer.String()).NotTo(ContainSubstring("skipping scheduled run"))
+	})
+})
@@ -0,0 +1,179 @@
+// Package memory calculates the JVM memory options of Logstash from the
+// container memory limit, similar to the memory calculator of the Java
+// buildpack: heap, thread stacks, metaspace and the direct memory limited by
+// the user together must fit into the memory available to the JVM.
+package memory
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultStackSize = 1024 // KB
+	DefaultMetaspace = 128  // MB
+	MinHeap          = 64   // MB
+
+	// BaseThreads is the number of threads of Logstash without workers
+	// (inputs, JRuby, GC, monitoring), ThreadsPerWorker the threads added by
+	// every pipeline worker.
+	BaseThreads      = 40
+	ThreadsPerWorker = 2
+)
+
+// Input of the memory calculation. Memory sizes are in MB.
+type Input struct {
+	TotalMemory    int    // container memory limit
+	ReservedMemory int    // memory not used by the JVM
+	HeapPercentage int    // upper bound of the heap, in percent of the memory available to the JVM
+	Workers        int    // pipeline workers of all pipelines
+	JavaOpts       string // JVM options of the user, explicit sizes take precedence
+}
+
+// Result of the memory calculation. Memory sizes are in MB, the stack size in
+// KB.
+type Result struct {
+	Heap         int
+	StackSize    int
+	Threads      int
+	Metaspace    int
+	DirectMemory int // -XX:MaxDirectMemorySize of the user, 0 if not limited
+	Available    int
+	InitialHeap  string   // -Xms of the user, defaults to Heap
+	JavaOpts     []string // other JVM options of the user
+}
+
+// Stacks returns the memory of all thread stacks in MB.
+func (r Result) Stacks() int {
+	return (r.Threads*r.StackSize + 1023) / 1024
+}
+
+// String returns the JVM options.
+func (r Result) String() string {
+	initialHeap := r.InitialHeap
+	if initialHeap == "" {
+		initialHeap = fmt.Sprintf("%dm", r.Heap)
+	}
+	opts := []string{
+		fmt.Sprintf("-Xmx%dm", r.Heap),
+		"-Xms" + initialHeap,
+		fmt.Sprintf("-Xss%dk", r.StackSize),
+		fmt.Sprintf("-XX:MaxMetaspaceSize=%dm", r.Metaspace),
+	}
+	if r.DirectMemory > 0 {
+		opts = append(opts, fmt.Sprintf("-XX:MaxDirectMemorySize=%dm", r.DirectMemory))
+	}
+	return strings.Join(append(opts, r.JavaOpts...), " ")
+}
+
+// Calculate returns the memory regions of the JVM. The heap gets
+// HeapPercentage of the available memory (TotalMemory - ReservedMemory) but
+// at most what is left by the other regions. Sizes defined in JavaOpts (-Xmx,
+// -Xss, -XX:MaxMetaspaceSize, -XX:MaxDirectMemorySize) are kept. The direct
+// memory is only limited if the user defines its size: netty based inputs
+// (beats, tcp, http) need more than a fixed share of the budget, it is left to
+// the reserved memory otherwise. It fails if the regions do not fit into the
+// available memory.
+func Calculate(in Input) (Result, error) {
+	r := Result{
+		StackSize: DefaultStackSize,
+		Metaspace: DefaultMetaspace,
+		Threads:   BaseThreads + ThreadsPerWorker*in.Workers,
+		Available: in.TotalMemory - in.ReservedMemory,
+		JavaOpts:  []string{},
+	}
+
+	if in.TotalMemory <= 0 {
+		return r, fmt.Errorf("the container memory limit is unknown")
+	}
+	if r.Available <= 0 {
+		return r, fmt.Errorf("the reserved memory (%dm) leaves no memory for the JVM (container memory limit %dm)", in.ReservedMemory, in.TotalMemory)
+	}
+
+	heap := 0
+	for _, opt := range strings.Fields(in.JavaOpts) {
+		var err error
+		switch {
+		case strings.HasPrefix(opt, "-Xmx"):
+			heap, err = ParseSize(strings.TrimPrefix(opt, "-Xmx"), 'm')
+		case strings.HasPrefix(opt, "-Xms"):
+			r.InitialHeap = strings.TrimPrefix(opt, "-Xms")
+		case strings.HasPrefix(opt, "-Xss"):
+			r.StackSize, err = ParseSize(strings.TrimPrefix(opt, "-Xss"), 'k')
+		case strings.HasPrefix(opt, "-XX:MaxMetaspaceSize="):
+			r.Metaspace, err = ParseSize(strings.TrimPrefix(opt, "-XX:MaxMetaspaceSize="), 'm')
+		case strings.HasPrefix(opt, "-XX:MaxDirectMemorySize="):
+			r.DirectMemory, err = ParseSize(strings.TrimPrefix(opt, "-XX:MaxDirectMemorySize="), 'm')
+		default:
+			r.JavaOpts = append(r.JavaOpts, opt)
+		}
+		if err != nil {
+			return r, fmt.Errorf("invalid JVM option %q: %s", opt, err.Error())
+		}
+	}
+
+	nonHeap := r.Metaspace + r.Stacks() + r.DirectMemory
+	left := r.Available - nonHeap
+
+	if heap > 0 {
+		if heap > left {
+			return r, fmt.Errorf("the heap of %dm does not fit into the available memory of %dm: %s", heap, r.Available, r.breakdown())
+		}
+		r.Heap = heap
+		return r, nil
+	}
+
+	r.Heap = r.Available * in.HeapPercentage / 100
+	if r.Heap > left {
+		r.Heap = left
+	}
+	if r.Heap < 0 {
+		r.Heap = 0
+	}
+	if r.Heap < MinHeap {
+		return r, fmt.Errorf("the available memory of %dm leaves %dm for the heap, the minimum is %dm: %s. Please increase the memory limit or reduce the reserved-memory", r.Available, r.Heap, MinHeap, r.breakdown())
+	}
+	return r, nil
+}
+
+func (r Result) breakdown() string {
+	breakdown := fmt.Sprintf("metaspace %dm, %d thread stacks of %dk (%dm)", r.Metaspace, r.Threads, r.StackSize, r.Stacks())
+	if r.DirectMemory > 0 {
+		breakdown += fmt.Sprintf(", direct memory %dm", r.DirectMemory)
+	}
+	return breakdown
+}
+
+// ParseSize parses a JVM memory size like 512m, 1g or 256k and returns it in
+// the given unit ('k' or 'm'). Sizes without unit are bytes.
+func ParseSize(size string, unit byte) (int, error) {
+	s := strings.ToLower(strings.TrimSpace(size))
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	factor := int64(1)
+	switch s[len(s)-1] {
+	case 'k':
+		factor = 1024
+	case 'm':
+		factor = 1024 * 1024
+	case 'g':
+		factor = 1024 * 1024 * 1024
+	}
+	if factor > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a valid size", size)
+	}
+
+	bytes := n * factor
+	if unit == 'k' {
+		return int(bytes / 1024), nil
+	}
+	return int(bytes / 1024 / 1024), nil
+}
@@ -0,0 +1,119 @@
+package memory_test
+
+import (
+	"logstash/memory"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Calculate", func() {
+	var (
+		in     memory.Input
+		result memory.Result
+		err    error
+	)
+
+	BeforeEach(func() {
+		in = memory.Input{TotalMemory: 2048, ReservedMemory: 300, HeapPercentage: 75, Workers: 4}
+	})
+
+	JustBeforeEach(func() {
+		result, err = memory.Calculate(in)
+	})
+
+	It("gives the heap percentage of the available memory", func() {
+		Expect(err).To(BeNil())
+		Expect(result.Available).To(Equal(1748))
+		Expect(result.Heap).To(Equal(1311))
+		Expect(result.Threads).To(Equal(48))
+		Expect(result.String()).To(Equal("-Xmx1311m -Xms1311m -Xss1024k -XX:MaxMetaspaceSize=128m"))
+	})
+
+	Context("with little memory", func() {
+		BeforeEach(func() {
+			in.TotalMemory = 1024
+			in.Workers = 16
+		})
+
+		It("reduces the heap to fit the other memory regions", func() {
+			Expect(err).To(BeNil())
+			Expect(result.Stacks()).To(Equal(72))
+			Expect(result.Heap).To(Equal(724 - 128 - 72))
+		})
+	})
+
+	Context("with sizes defined by the user", func() {
+		BeforeEach(func() {
+			in.JavaOpts = "-Xmx1g -Xms512m -Xss512k -XX:MaxMetaspaceSize=256m -XX:+UseG1GC"
+		})
+
+		It("keeps them and the other options", func() {
+			Expect(err).To(BeNil())
+			Expect(result.String()).To(Equal("-Xmx1024m -Xms512m -Xss512k -XX:MaxMetaspaceSize=256m -XX:+UseG1GC"))
+		})
+	})
+
+	Context("with the direct memory limited by the user", func() {
+		BeforeEach(func() {
+			in.TotalMemory = 1024
+			in.JavaOpts = "-XX:MaxDirectMemorySize=256m"
+		})
+
+		It("limits it and reduces the heap", func() {
+			Expect(err).To(BeNil())
+			Expect(result.DirectMemory).To(Equal(256))
+			Expect(result.Heap).To(Equal(724 - 128 - 48 - 256))
+			Expect(result.String()).To(HaveSuffix(" -XX:MaxDirectMemorySize=256m"))
+		})
+	})
+
+	Context("with a heap too large for the memory limit", func() {
+		BeforeEach(func() {
+			in.JavaOpts = "-Xmx2g"
+		})
+
+		It("fails", func() {
+			Expect(err).To(MatchError(ContainSubstring("the heap of 2048m does not fit into the available memory of 1748m")))
+		})
+	})
+
+	Context("with too little memory left for the heap", func() {
+		BeforeEach(func() {
+			in.TotalMemory = 512
+		})
+
+		It("fails", func() {
+			Expect(err).To(MatchError(ContainSubstring("leaves 36m for the heap, the minimum is 64m")))
+		})
+	})
+
+	Context("with the reserved memory above the limit", func() {
+		BeforeEach(func() {
+			in.ReservedMemory = 4096
+		})
+
+		It("fails", func() {
+			Expect(err).To(MatchError(ContainSubstring("leaves no memory for the JVM")))
+		})
+	})
+
+	Context("with an invalid size", func() {
+		BeforeEach(func() {
+			in.JavaOpts = "-Xmxlots"
+		})
+
+		It("fails", func() {
+			Expect(err).To(MatchError(`invalid JVM option "-Xmxlots": "lots" is not a valid size`))
+		})
+	})
+})
+
+var _ = Describe("ParseSize", func() {
+	It("converts to the unit", func() {
+		Expect(memory.ParseSize("1g", 'm')).To(Equal(1024))
+		Expect(memory.ParseSize("512M", 'm')).To(Equal(512))
+		Expect(memory.ParseSize("1m", 'k')).To(Equal(1024))
+		Expect(memory.ParseSize("1048576", 'k')).To(Equal(1024))
+	})
+})
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenRegexp
+	tokenPunct
+	tokenTemplate
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+const punct = "{}[](),"
+
+const operators = "=!<>~"
+
+// templateByte replaces the template actions before scanning.
+const templateByte byte = 0
+
+type lexer struct {
+	text   string
+	pos    int
+	line   int
+	last   token
+	peeked *token
+}
+
+func (l *lexer) peek() (token, error) {
+	if l.peeked == nil {
+		t, err := l.scan()
+		if err != nil {
+			return t, err
+		}
+		l.peeked = &t
+	}
+	return *l.peeked, nil
+}
+
+func (l *lexer) next() (token, error) {
+	t, err := l.peek()
+	l.peeked = nil
+	l.last = t
+	return t, err
+}
+
+func (l *lexer) scan() (token, error) {
+	l.skipSpace()
+	if l.pos >= len(l.text) {
+		return token{kind: tokenEOF, line: l.line}, nil
+	}
+
+	start, line := l.pos, l.line
+	c := l.text[l.pos]
+	switch {
+	case c == templateByte:
+		l.pos++
+		return token{kind: tokenTemplate, text: "{{ }}", line: line}, nil
+	case c == '"' || c == '\'':
+		return l.scanQuoted(tokenString, c)
+	case c == '/' && (l.last.text == "=~" || l.last.text == "!~"):
+		return l.scanQuoted(tokenRegexp, c)
+	case strings.IndexByte(punct, c) >= 0:
+		l.pos++
+	case strings.IndexByte(operators, c) >= 0:
+		for l.pos < len(l.text) && strings.IndexByte(operators, l.text[l.pos]) >= 0 {
+			l.pos++
+		}
+	default:
+		for l.pos < len(l.text) && !isDelimiter(l.text[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokenWord, text: l.text[start:l.pos], line: line}, nil
+	}
+	return token{kind: tokenPunct, text: l.text[start:l.pos], line: line}, nil
+}
+
+// scanQuoted scans a string or a regular expression, backslashes escape the
+// next character.
+func (l *lexer) scanQuoted(kind tokenKind, quote byte) (token, error) {
+	start, line := l.pos, l.line
+	for l.pos++; l.pos < len(l.text); l.pos++ {
+		switch l.text[l.pos] {
+		case '\\':
+			l.pos++
+		case '\n':
+			l.line++
+		case quote:
+			l.pos++
+			return token{kind: kind, text: l.text[start:l.pos], line: line}, nil
+		}
+	}
+	return token{}, fmt.Errorf("line %d: unterminated %s", line, map[tokenKind]string{tokenString: "string", tokenRegexp: "regular expression"}[kind])
+}
+
+// skipSpace skips whitespace and comments.
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.text) {
+		switch c := l.text[l.pos]; {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.text) && l.text[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isDelimiter(c byte) bool {
+	return c == templateByte || c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '#' || c == '"' || c == '\'' ||
+		strings.IndexByte(punct, c) >= 0 || strings.IndexByte(operators, c) >= 0
+}
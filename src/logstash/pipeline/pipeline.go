@@ -0,0 +1,260 @@
+// Package pipeline reads the plugins referenced by Logstash pipeline configs.
+// It understands the structure of the config language (sections, plugins,
+// conditionals, attributes and codecs) but does not validate values, this is
+// left to the Logstash config check.
+package pipeline
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PluginTypes are the sections of a pipeline config and the codecs, the gem
+// of a plugin is named logstash-<type>-<name>.
+var PluginTypes = []string{"input", "filter", "output", "codec"}
+
+// GemName returns the gem of a plugin, e.g. logstash-filter-mutate.
+func GemName(pluginType, name string) string {
+	return "logstash-" + pluginType + "-" + name
+}
+
+// template actions of configs rendered at startup, e.g. {{ .Env.PORT }}
+var templateAction = regexp.MustCompile(`(?s){{.*?}}`)
+
+// Plugins returns the gems of the plugins referenced by the config, sorted
+// and without duplicates. Template actions may be given as values, they are
+// ignored elsewhere, the plugins of all branches of a template count as
+// referenced.
+func Plugins(config string) ([]string, error) {
+	p := &parser{lexer: &lexer{text: templateAction.ReplaceAllStringFunc(config, placeholder), line: 1}, plugins: map[string]bool{}}
+	if err := p.parseConfig(); err != nil {
+		return nil, err
+	}
+
+	plugins := []string{}
+	for plugin := range p.plugins {
+		plugins = append(plugins, plugin)
+	}
+	sort.Strings(plugins)
+	return plugins, nil
+}
+
+// DirPlugins returns the gems of the plugins referenced by the config files
+// in the directory and its subdirectories. A missing directory has no
+// plugins.
+func DirPlugins(dir string) ([]string, error) {
+	unique := map[string]bool{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		plugins, err := Plugins(string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err.Error())
+		}
+		for _, plugin := range plugins {
+			unique[plugin] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plugins := []string{}
+	for plugin := range unique {
+		plugins = append(plugins, plugin)
+	}
+	sort.Strings(plugins)
+	return plugins, nil
+}
+
+// placeholder replaces a template action with a single tokenTemplate,
+// keeping the newlines for the line numbers of errors.
+func placeholder(action string) string {
+	return string(templateByte) + strings.Repeat("\n", strings.Count(action, "\n"))
+}
+
+type parser struct {
+	*lexer
+	plugins map[string]bool
+}
+
+// parseConfig parses the sections: (input|filter|output) { plugins }
+func (p *parser) parseConfig() error {
+	for {
+		t, err := p.next()
+		if err != nil || t.kind == tokenEOF {
+			return err
+		}
+		if t.kind == tokenTemplate {
+			continue
+		}
+		if t.kind != tokenWord || (t.text != "input" && t.text != "filter" && t.text != "output") {
+			return p.errorf(t, "expected input, filter or output")
+		}
+		if err := p.expect("{"); err != nil {
+			return err
+		}
+		if err := p.parsePlugins(t.text); err != nil {
+			return err
+		}
+	}
+}
+
+// parsePlugins parses plugins and conditionals up to the closing brace.
+func (p *parser) parsePlugins(pluginType string) error {
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case t.kind == tokenTemplate:
+			continue
+		case t.text == "}":
+			return nil
+		case t.kind == tokenWord && (t.text == "if" || t.text == "else"):
+			if err := p.skipCondition(); err != nil {
+				return err
+			}
+			if err := p.parsePlugins(pluginType); err != nil {
+				return err
+			}
+		case t.kind == tokenWord:
+			p.plugins[GemName(pluginType, t.text)] = true
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			if err := p.parseAttributes(); err != nil {
+				return err
+			}
+		default:
+			return p.errorf(t, "expected a plugin or a conditional")
+		}
+	}
+}
+
+// skipCondition skips the condition of if and else if up to the opening
+// brace of the branch.
+func (p *parser) skipCondition() error {
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch t.text {
+		case "{":
+			return nil
+		case "[", "(":
+			if err := p.skipNested(t.text); err != nil {
+				return err
+			}
+		}
+		if t.kind == tokenEOF || t.text == "}" {
+			return p.errorf(t, "expected { after the condition")
+		}
+	}
+}
+
+// parseAttributes parses name => value pairs up to the closing brace of the
+// plugin, codecs are given as name or as name { attributes }.
+func (p *parser) parseAttributes() error {
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+		if t.text == "}" {
+			return nil
+		}
+		if t.kind == tokenTemplate {
+			continue
+		}
+		if t.kind != tokenWord && t.kind != tokenString {
+			return p.errorf(t, "expected an attribute name")
+		}
+		if err := p.expect("=>"); err != nil {
+			return err
+		}
+
+		value, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case value.text == "{" || value.text == "[":
+			if err := p.skipNested(value.text); err != nil {
+				return err
+			}
+		case t.text == "codec" && value.kind == tokenWord:
+			p.plugins[GemName("codec", value.text)] = true
+			if next, err := p.peek(); err != nil {
+				return err
+			} else if next.text == "{" {
+				p.next()
+				if err := p.parseAttributes(); err != nil {
+					return err
+				}
+			}
+		case value.kind != tokenWord && value.kind != tokenString && value.kind != tokenTemplate:
+			return p.errorf(value, "expected a value of %s", t.text)
+		}
+	}
+}
+
+// skipNested skips a hash, an array or a parenthesized expression up to its
+// closing bracket.
+func (p *parser) skipNested(open string) error {
+	closing := map[string]string{"{": "}", "[": "]", "(": ")"}[open]
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case t.kind == tokenEOF:
+			return p.errorf(t, "expected %s", closing)
+		case t.text == closing:
+			return nil
+		case t.text == "{" || t.text == "[" || t.text == "(":
+			if err := p.skipNested(t.text); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *parser) expect(text string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if t.text != text || t.kind == tokenString {
+		return p.errorf(t, "expected %s", text)
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	found := fmt.Sprintf("%q", t.text)
+	if t.kind == tokenEOF {
+		found = "end of file"
+	}
+	return fmt.Errorf("line %d: %s, found %s", t.line, fmt.Sprintf(format, args...), found)
+}
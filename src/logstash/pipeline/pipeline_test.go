@@ -0,0 +1,142 @@
+package pipeline_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"logstash/pipeline"
+	"logstash/render"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Plugins", func() {
+	It("finds the plugins of all sections, branches and codecs", func() {
+		plugins, err := pipeline.Plugins(`
+# a comment with input { ignored {} }
+input {
+  tcp { port => 5000 codec => json_lines }
+  http {
+    port => "${HTTP_PORT}"
+    codec => line { format => "{ not a plugin }" }
+  }
+}
+filter {
+  if [type] == "syslog" and [message] =~ /^\{.*\}$/ {
+    grok { match => { "message" => "%{SYSLOGLINE}" } }
+  } else if "json" in [tags] {
+    json { source => "message" }
+  } else {
+    mutate { add_tag => ["other", "{ }"] remove_field => [ "[a][b]" ] }
+  }
+  grok { match => { "message" => "%{WORD:verb}" } }
+}
+output {
+  elasticsearch { hosts => ["es:9200"] }
+  stdout { codec => rubydebug }
+}
+`)
+		Expect(err).To(BeNil())
+		Expect(plugins).To(Equal([]string{
+			"logstash-codec-json_lines", "logstash-codec-line", "logstash-codec-rubydebug",
+			"logstash-filter-grok", "logstash-filter-json", "logstash-filter-mutate",
+			"logstash-input-http", "logstash-input-tcp",
+			"logstash-output-elasticsearch", "logstash-output-stdout",
+		}))
+	})
+
+	It("accepts template actions as values and ignores them elsewhere", func() {
+		plugins, err := pipeline.Plugins(`
+output {
+  {{ if .Env.DEBUG }}
+  stdout { codec => {{ default "rubydebug" .Env.CODEC }} }
+  {{ end }}
+  http {
+    url => {{ jsonQuery .Env.VCAP_SERVICES "*[].credentials.uri | [0]" }}
+    headers => { "Authorization" => "{{ .Env.AUTH "header" }}" }
+  }
+}
+`)
+		Expect(err).To(BeNil())
+		Expect(plugins).To(Equal([]string{"logstash-output-http", "logstash-output-stdout"}))
+	})
+
+	It("reports syntax errors with their line", func() {
+		_, err := pipeline.Plugins("input {\n  stdin {}\n}\nfilters {\n}\n")
+		Expect(err).To(MatchError(`line 4: expected input, filter or output, found "filters"`))
+
+		_, err = pipeline.Plugins("output {\n  stdout {\n    codec =>\n  }\n}\n")
+		Expect(err).To(MatchError(`line 4: expected a value of codec, found "}"`))
+
+		_, err = pipeline.Plugins("filter {\n  mutate { add_tag => \"open }\n}\n")
+		Expect(err).To(MatchError("line 2: unterminated string"))
+
+		_, err = pipeline.Plugins("filter {\n  mutate {\n")
+		Expect(err).To(MatchError("line 3: expected an attribute name, found end of file"))
+	})
+
+	Describe("DirPlugins", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "pipeline")
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("merges the plugins of all files of the directory and its subdirectories", func() {
+			Expect(os.MkdirAll(filepath.Join(dir, "sub"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "input.conf"), []byte("input { stdin {} }"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "sub", "output.conf"), []byte("output { stdout {} }\ninput { stdin {} }"), 0644)).To(Succeed())
+
+			Expect(pipeline.DirPlugins(dir)).To(Equal([]string{"logstash-input-stdin", "logstash-output-stdout"}))
+		})
+
+		It("reports the file of a syntax error", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "input.conf"), []byte("input { stdin }"), 0644)).To(Succeed())
+
+			_, err := pipeline.DirPlugins(dir)
+			Expect(err).To(MatchError(filepath.Join(dir, "input.conf") + `: line 1: expected {, found "}"`))
+		})
+
+		It("has no plugins for a missing directory", func() {
+			Expect(pipeline.DirPlugins(filepath.Join(dir, "missing"))).To(BeEmpty())
+		})
+	})
+
+	It("parses the config templates of the buildpack rendered at staging", func() {
+		files, err := filepath.Glob(filepath.Join("..", "..", "..", "defaults", "templates", "*.conf"))
+		Expect(err).To(BeNil())
+		Expect(files).NotTo(BeEmpty())
+
+		variable := regexp.MustCompile(`\.Env\.(\w+)`)
+		for _, file := range files {
+			text, err := ioutil.ReadFile(file)
+			Expect(err).To(BeNil())
+			env := map[string]string{"CREDENTIALS_SHAPE": "hosts"}
+			for _, m := range variable.FindAllStringSubmatch(string(text), -1) {
+				if _, ok := env[m[1]]; !ok {
+					env[m[1]] = "x"
+				}
+			}
+			staged, err := (&render.Renderer{Delims: render.StagingDelims, Env: env}).Render(file, string(text))
+			Expect(err).To(BeNil())
+
+			plugins, err := pipeline.Plugins(string(staged))
+			Expect(err).To(BeNil(), file)
+			Expect(plugins).NotTo(BeEmpty(), file)
+			pluginType := strings.Split(filepath.Base(file), "-")[1]
+			for _, plugin := range plugins {
+				Expect(plugin).To(Or(HavePrefix("logstash-"+pluginType+"-"), HavePrefix("logstash-codec-")), file)
+			}
+		}
+	})
+})
@@ -0,0 +1,121 @@
+package render
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Funcs are the functions available in the templates, the same as in the
+// templates of dockerize plus toJson.
+var Funcs = template.FuncMap{
+	"add":       add,
+	"atoi":      atoi,
+	"contains":  contains,
+	"default":   defaultValue,
+	"exists":    exists,
+	"isTrue":    isTrue,
+	"join":      join,
+	"jsonQuery": JSONQuery,
+	"loop":      loop,
+	"lower":     strings.ToLower,
+	"parseUrl":  parseURL,
+	"replace":   strings.Replace,
+	"split":     strings.Split,
+	"toJson":    ToJSON,
+	"upper":     strings.ToUpper,
+}
+
+// add returns the sum of two integers.
+func add(a, b int) int {
+	return a + b
+}
+
+// atoi converts a string to an integer, invalid strings are 0.
+func atoi(s string) int {
+	i, _ := strconv.Atoi(strings.TrimSpace(s))
+	return i
+}
+
+// contains returns whether the map has the key.
+func contains(m map[string]string, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// defaultValue returns the value unless it is missing or empty, the first
+// default otherwise: {{ default .Env.TYPE "syslog" }}.
+func defaultValue(value interface{}, defaults ...interface{}) (interface{}, error) {
+	if len(defaults) == 0 {
+		return nil, fmt.Errorf("default needs a default value")
+	}
+	if value == nil {
+		return defaults[0], nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if v.Len() == 0 {
+			return defaults[0], nil
+		}
+	}
+	return value, nil
+}
+
+// exists returns whether the file exists.
+func exists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// isTrue returns whether the string is a true boolean (1, t, true, ...).
+func isTrue(s string) bool {
+	b, _ := strconv.ParseBool(strings.TrimSpace(s))
+	return b
+}
+
+// join joins the elements of a list with the separator.
+func join(elements []string, sep string) string {
+	return strings.Join(elements, sep)
+}
+
+// loop returns the integers [start, stop) with the step: loop stop, loop
+// start stop or loop start stop step.
+func loop(args ...int) ([]int, error) {
+	start, stop, step := 0, 0, 1
+	switch len(args) {
+	case 1:
+		stop = args[0]
+	case 2:
+		start, stop = args[0], args[1]
+	case 3:
+		start, stop, step = args[0], args[1], args[2]
+	default:
+		return nil, fmt.Errorf("loop takes 1 to 3 arguments, got %d", len(args))
+	}
+	if step == 0 {
+		return nil, fmt.Errorf("loop step cannot be 0")
+	}
+
+	values := []int{}
+	for i := start; step > 0 && i < stop || step < 0 && i > stop; i += step {
+		values = append(values, i)
+	}
+	return values, nil
+}
+
+// parseURL parses a URL: {{ (parseUrl .Env.URL).Host }}.
+func parseURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the URL %q: %s", rawURL, err.Error())
+	}
+	return u, nil
+}
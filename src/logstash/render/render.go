@@ -0,0 +1,133 @@
+// Package render renders the templates of the buildpack (Logstash config,
+// grok patterns, Curator) with text/template, compatible with the templates
+// of dockerize and gte: the environment is available as .Env and the
+// functions of Funcs (e.g. jsonQuery, default and split) can be used.
+// jsonQuery returns the raw result like dockerize, toJson (not in dockerize)
+// encodes it as JSON for the Logstash config.
+//
+// The templates are rendered twice: at staging with the delimiters << >>
+// (StagingDelims) and at runtime with the default delimiters {{ }}.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+// StagingDelims are the delimiters of the templates rendered at staging.
+var StagingDelims = [2]string{"<<", ">>"}
+
+// A Renderer renders templates with the environment Env.
+type Renderer struct {
+	Delims [2]string // empty for the default delimiters {{ }}
+	Env    map[string]string
+}
+
+// New returns a Renderer for the environment of the process with the
+// given delimiters.
+func New(delims [2]string) *Renderer {
+	return &Renderer{Delims: delims, Env: Environ()}
+}
+
+// Environ returns the environment of the process as map.
+func Environ() map[string]string {
+	env := map[string]string{}
+	for _, e := range os.Environ() {
+		if i := strings.Index(e, "="); i > 0 {
+			env[e[:i]] = e[i+1:]
+		}
+	}
+	return env
+}
+
+// Render renders the template text. The name is used in the error
+// messages which report the line (and column) of the error.
+func (r *Renderer) Render(name, text string) ([]byte, error) {
+	t, err := template.New(name).Delims(r.Delims[0], r.Delims[1]).Option("missingkey=zero").Funcs(Funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := t.Execute(&out, struct{ Env map[string]string }{r.Env}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// RenderFile renders the template src to dest, the file mode is kept.
+func (r *Renderer) RenderFile(src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	text, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	out, err := r.Render(src, string(text))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dest, out, info.Mode().Perm())
+}
+
+// RenderDir renders all files of the directory src (recursively) to the
+// same paths in dest. Existing files in dest are overwritten.
+func (r *Renderer) RenderDir(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dest, rel), 0755)
+		}
+		return r.RenderFile(path, filepath.Join(dest, rel))
+	})
+}
+
+// JSONQuery evaluates the JMESPath expression against the JSON document and
+// returns the result as is, like dockerize: a string is not quoted and a list
+// is printed as [a b]. Pipe it to toJson for the JSON of the result.
+func JSONQuery(document, expression string) (interface{}, error) {
+	var data interface{}
+	if err := json.Unmarshal([]byte(document), &data); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %s", err.Error())
+	}
+	query, err := jmespath.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JMESPath expression %q: %s", expression, err.Error())
+	}
+	result, err := query.Search(data)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %s", expression, err.Error())
+	}
+	return result, nil
+}
+
+// ToJSON returns the value as JSON, e.g. a string is quoted:
+// {{ jsonQuery .Env.VCAP_SERVICES "elasticsearch[0].credentials.host" | toJson }}.
+func ToJSON(value interface{}) (string, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
@@ -0,0 +1,292 @@
+package render_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"logstash/render"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Renderer", func() {
+	var r *render.Renderer
+
+	BeforeEach(func() {
+		r = &render.Renderer{Env: map[string]string{
+			"PORT":          "8080",
+			"HOSTS":         "a,b",
+			"URL":           "https://user@es:9200/path",
+			"DEBUG":         "true",
+			"VCAP_SERVICES": `{"elasticsearch": [{"name": "es", "credentials": {"host": "https://es:9200"}}]}`,
+		}}
+	})
+
+	It("renders the environment and the functions", func() {
+		out, err := r.Render("test.conf", `port => {{ .Env.PORT }}
+type => {{ default .Env.TYPE "syslog" }}
+hosts => {{ range split .Env.HOSTS "," }}{{ upper . }} {{ end }}
+host => {{ (parseUrl .Env.URL).Host }}
+next => {{ add (atoi .Env.PORT) 1 }}
+debug => {{ isTrue .Env.DEBUG }} {{ contains .Env "DEBUG" }} {{ contains .Env "MISSING" }}
+loop => {{ range loop 3 }}{{ . }}{{ end }}
+url => {{ jsonQuery .Env.VCAP_SERVICES "elasticsearch[0].credentials.host" }}
+json => {{ jsonQuery .Env.VCAP_SERVICES "elasticsearch[0].credentials.host" | toJson }}
+list => {{ jsonQuery .Env.VCAP_SERVICES "elasticsearch[].name" }} {{ jsonQuery .Env.VCAP_SERVICES "elasticsearch[].name" | toJson }}
+missing => "{{ .Env.MISSING }}"`)
+		Expect(err).To(BeNil())
+		Expect(string(out)).To(Equal(`port => 8080
+type => syslog
+hosts => A B 
+host => es:9200
+next => 8081
+debug => true true false
+loop => 012
+url => https://es:9200
+json => "https://es:9200"
+list => [es] ["es"]
+missing => ""`))
+	})
+
+	It("uses the staging delimiters", func() {
+		r.Delims = render.StagingDelims
+		out, err := r.Render("test.conf", `port => << .Env.PORT >> {{ .Env.PORT }}`)
+		Expect(err).To(BeNil())
+		Expect(string(out)).To(Equal(`port => 8080 {{ .Env.PORT }}`))
+	})
+
+	It("reports the line of errors", func() {
+		_, err := r.Render("test.conf", "input {\n  {{ .Env.PORT \n}")
+		Expect(err).To(MatchError(ContainSubstring("test.conf:3")))
+
+		_, err = r.Render("test.conf", "input {\n\n  {{ jsonQuery .Env.VCAP_SERVICES \"elasticsearch[\" }}\n}")
+		Expect(err).To(MatchError(ContainSubstring("test.conf:3")))
+		Expect(err).To(MatchError(ContainSubstring("invalid JMESPath expression")))
+	})
+
+	Describe("RenderDir", func() {
+		var src, dest string
+
+		BeforeEach(func() {
+			var err error
+			src, err = ioutil.TempDir("", "render.src")
+			Expect(err).To(BeNil())
+			dest, err = ioutil.TempDir("", "render.dest")
+			Expect(err).To(BeNil())
+
+			Expect(os.MkdirAll(filepath.Join(src, "sub"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(src, "input.conf"), []byte("port => {{ .Env.PORT }}"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(src, "sub", "run.sh"), []byte("echo {{ .Env.DEBUG }}"), 0755)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(src)
+			os.RemoveAll(dest)
+		})
+
+		It("renders all files and keeps the file modes", func() {
+			Expect(r.RenderDir(src, dest)).To(Succeed())
+
+			Expect(ioutil.ReadFile(filepath.Join(dest, "input.conf"))).To(Equal([]byte("port => 8080")))
+			Expect(ioutil.ReadFile(filepath.Join(dest, "sub", "run.sh"))).To(Equal([]byte("echo true")))
+			info, err := os.Stat(filepath.Join(dest, "sub", "run.sh"))
+			Expect(err).To(BeNil())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+		})
+
+		It("reports the file of errors", func() {
+			Expect(ioutil.WriteFile(filepath.Join(src, "sub", "broken.conf"), []byte("{{ .Env.PORT | missing }}"), 0644)).To(Succeed())
+			Expect(r.RenderDir(src, dest)).To(MatchError(ContainSubstring(filepath.Join(src, "sub", "broken.conf") + ":1")))
+		})
+	})
+
+	Describe("the default templates", func() {
+		var vcapServices = `{
+			"elasticsearch": [{"name": "logs", "label": "elasticsearch", "credentials": {"host": ["https://es1:9200", "https://es2:9200"], "username": "admin", "password": "secret"}}],
+			"user-provided": [{"name": "drain", "label": "user-provided", "credentials": {"uri": "http://es:9200"}},
+				{"name": "webhook", "label": "user-provided", "credentials": {"url": "https://hook.example.com/logs", "username": "hook", "password": "hookpw"}},
+				{"name": "forwarder", "label": "user-provided", "credentials": {"host": "syslog.example.com"}}],
+			"kafka": [{"name": "events", "label": "kafka", "credentials": {"brokers": ["k1:9092", "k2:9092"]}},
+				{"name": "events-uri", "label": "kafka", "credentials": {"brokers": "k1:9092,k2:9092"}}],
+			"rabbitmq": [{"name": "queue", "label": "rabbitmq", "credentials": {"hostname": "mq", "username": "guest", "password": "guestpw", "vhost": "logs"}}],
+			"redis": [{"name": "cache", "label": "redis", "credentials": {"host": "redis", "port": 6380, "password": "redispw"}}],
+			"s3": [{"name": "archive", "label": "s3", "credentials": {"bucket": "logs", "access_key_id": "AKIA", "secret_access_key": "s3secret"}}]
+		}`
+
+		renderTwice := func(file string, env map[string]string) string {
+			text, err := ioutil.ReadFile(filepath.Join("..", "..", "..", "defaults", "templates", file))
+			Expect(err).To(BeNil())
+			staging := &render.Renderer{Delims: render.StagingDelims, Env: env}
+			out, err := staging.Render(file, string(text))
+			Expect(err).To(BeNil())
+
+			runtime := &render.Renderer{Env: map[string]string{"VCAP_SERVICES": vcapServices, "HOME": "/home/vcap/app"}}
+			out, err = runtime.Render(file, string(out))
+			Expect(err).To(BeNil())
+			return string(out)
+		}
+
+		It("renders the Elasticsearch output for hosts credentials", func() {
+			out := renderTwice("cf-output-elasticsearch.conf", map[string]string{
+				"TEMPLATE_ID": "cf-output-elasticsearch-logs", "SERVICE_INSTANCE_NAME": "logs",
+				"CREDENTIALS_SHAPE": "hosts", "CREDENTIALS_HOST_FIELD": "host",
+				"CREDENTIALS_USERNAME_FIELD": "username", "CREDENTIALS_PASSWORD_FIELD": "password",
+				"PARAM_INDEX": "logs-%{+YYYY.MM.dd}", "PARAM_SSL_VERIFY": "true",
+			})
+			Expect(out).To(ContainSubstring(`hosts =>  ["https://es1:9200","https://es2:9200"]`))
+			Expect(out).To(ContainSubstring(`user => "admin"`))
+			Expect(out).To(ContainSubstring(`password => "secret"`))
+			Expect(out).To(ContainSubstring(`ssl => true`))
+		})
+
+		It("renders the Elasticsearch output for a user-provided uri", func() {
+			out := renderTwice("cf-output-elasticsearch.conf", map[string]string{
+				"TEMPLATE_ID": "cf-output-elasticsearch-drain", "SERVICE_INSTANCE_NAME": "drain",
+				"CREDENTIALS_SHAPE": "fields", "CREDENTIALS_HOST_FIELD": "uri",
+				"PARAM_INDEX": "logs", "PARAM_SSL_VERIFY": "true",
+			})
+			Expect(out).To(ContainSubstring(`hosts =>  ["http://es:9200"]`))
+			Expect(out).NotTo(ContainSubstring("user =>"))
+			Expect(out).To(ContainSubstring(`ssl => false`))
+		})
+
+		It("renders the Kafka output for a list of brokers", func() {
+			out := renderTwice("cf-output-kafka.conf", map[string]string{
+				"TEMPLATE_ID": "cf-output-kafka-events", "SERVICE_INSTANCE_NAME": "events",
+				"CREDENTIALS_SHAPE": "hosts", "CREDENTIALS_HOST_FIELD": "brokers",
+				"PARAM_TOPIC_ID": "logs", "PARAM_CODEC": "json",
+			})
+			Expect(out).To(ContainSubstring(`id => "cf-output-kafka-events"`))
+			Expect(out).To(ContainSubstring(`bootstrap_servers => "k1:9092,k2:9092"`))
+			Expect(out).To(ContainSubstring(`topic_id => "logs"`))
+			Expect(out).To(ContainSubstring(`codec => json`))
+		})
+
+		It("renders the Kafka output for a brokers string", func() {
+			out := renderTwice("cf-output-kafka.conf", map[string]string{
+				"TEMPLATE_ID": "cf-output-kafka-events-uri", "SERVICE_INSTANCE_NAME": "events-uri",
+				"CREDENTIALS_SHAPE": "fields", "CREDENTIALS_HOST_FIELD": "brokers",
+				"PARAM_TOPIC_ID": "logstash", "PARAM_CODEC": "json",
+			})
+			Expect(out).To(ContainSubstring(`bootstrap_servers => "k1:9092,k2:9092"`))
+		})
+
+		It("renders the RabbitMQ output", func() {
+			out := renderTwice("cf-output-rabbitmq.conf", map[string]string{
+				"TEMPLATE_ID": "cf-output-rabbitmq-queue", "SERVICE_INSTANCE_NAME": "queue",
+				"CREDENTIALS_SHAPE": "fields", "CREDENTIALS_HOST_FIELD": "hostname",
+				"CREDENTIALS_USERNAME_FIELD": "username", "CREDENTIALS_PASSWORD_FIELD": "password",
+				"PARAM_EXCHANGE": "logstash", "PARAM_EXCHANGE_TYPE": "direct", "PARAM_KEY": "logs", "PARAM_CODEC": "json",
+			})
+			Expect(out).To(ContainSubstring(`host => "mq"`))
+			Expect(out).To(ContainSubstring(`user => "guest"`))
+			Expect(out).To(ContainSubstring(`password => "guestpw"`))
+			Expect(out).To(ContainSubstring(`vhost => "logs"`))
+			Expect(out).To(ContainSubstring(`ssl => "false"`))
+			Expect(out).To(ContainSubstring(`exchange_type => "direct"`))
+			Expect(out).To(ContainSubstring(`key => "logs"`))
+		})
+
+		It("renders the Redis output", func() {
+			out := renderTwice("cf-output-redis.conf", map[string]string{
+				"TEMPLATE_ID": "cf-output-redis-cache", "SERVICE_INSTANCE_NAME": "cache",
+				"CREDENTIALS_SHAPE": "fields", "CREDENTIALS_HOST_FIELD": "host", "CREDENTIALS_PASSWORD_FIELD": "password",
+				"PARAM_DATA_TYPE": "list", "PARAM_KEY": "logstash", "PARAM_CODEC": "json",
+			})
+			Expect(out).To(ContainSubstring(`host => "redis"`))
+			Expect(out).To(ContainSubstring(`port => 6380`))
+			Expect(out).To(ContainSubstring(`password => "redispw"`))
+			Expect(out).To(ContainSubstring(`data_type => "list"`))
+		})
+
+		It("renders the S3 output with the region of the params and an endpoint", func() {
+			out := renderTwice("cf-output-s3.conf", map[string]string{
+				"TEMPLATE_ID": "cf-output-s3-archive", "SERVICE_INSTANCE_NAME": "archive",
+				"CREDENTIALS_SHAPE": "fields", "CREDENTIALS_HOST_FIELD": "bucket",
+				"CREDENTIALS_USERNAME_FIELD": "access_key_id", "CREDENTIALS_PASSWORD_FIELD": "secret_access_key",
+				"PARAM_REGION": "eu-west-1", "PARAM_ENDPOINT": "https://s3.example.com", "PARAM_PREFIX": "logs/", "PARAM_CODEC": "json_lines",
+			})
+			Expect(out).To(ContainSubstring(`bucket => "logs"`))
+			Expect(out).To(ContainSubstring(`access_key_id => "AKIA"`))
+			Expect(out).To(ContainSubstring(`secret_access_key => "s3secret"`))
+			Expect(out).To(ContainSubstring(`region => "eu-west-1"`))
+			Expect(out).To(ContainSubstring(`endpoint => "https://s3.example.com"`))
+			Expect(out).To(ContainSubstring(`prefix => "logs/"`))
+		})
+
+		It("renders the HTTP output", func() {
+			out := renderTwice("cf-output-http.conf", map[string]string{
+				"TEMPLATE_ID": "cf-output-http-webhook", "SERVICE_INSTANCE_NAME": "webhook",
+				"CREDENTIALS_SHAPE": "fields", "CREDENTIALS_HOST_FIELD": "url",
+				"CREDENTIALS_USERNAME_FIELD": "username", "CREDENTIALS_PASSWORD_FIELD": "password",
+				"PARAM_HTTP_METHOD": "post", "PARAM_FORMAT": "json",
+			})
+			Expect(out).To(ContainSubstring(`url => "https://hook.example.com/logs"`))
+			Expect(out).To(ContainSubstring(`user => "hook"`))
+			Expect(out).To(ContainSubstring(`password => "hookpw"`))
+			Expect(out).To(ContainSubstring(`http_method => "post"`))
+		})
+
+		It("renders the syslog output with the port of the params", func() {
+			out := renderTwice("cf-output-syslog.conf", map[string]string{
+				"TEMPLATE_ID": "cf-output-syslog-forwarder", "SERVICE_INSTANCE_NAME": "forwarder",
+				"CREDENTIALS_SHAPE": "fields", "CREDENTIALS_HOST_FIELD": "host",
+				"PARAM_PORT": "514", "PARAM_PROTOCOL": "tcp", "PARAM_RFC": "rfc5424", "PARAM_APPNAME": "logstash",
+			})
+			Expect(out).To(ContainSubstring(`host => "syslog.example.com"`))
+			Expect(out).To(ContainSubstring(`port => "514"`))
+			Expect(out).To(ContainSubstring(`protocol => "tcp"`))
+		})
+
+		// grokPattern returns the definition of a pattern in the grok patterns of the buildpack
+		grokPattern := func(file, name string) string {
+			patterns, err := ioutil.ReadFile(filepath.Join("..", "..", "..", "defaults", "grok-patterns", file))
+			Expect(err).To(BeNil())
+			for _, line := range strings.Split(string(patterns), "\n") {
+				if strings.HasPrefix(line, name+" ") {
+					return line
+				}
+			}
+			return ""
+		}
+
+		It("renders the log drain filter", func() {
+			out := renderTwice("cf-filter-log-drain.conf", map[string]string{"TEMPLATE_ID": "cf-filter-log-drain"})
+			Expect(out).To(ContainSubstring(`id => "cf-filter-log-drain"`))
+			Expect(out).To(ContainSubstring(`patterns_dir => [ "/home/vcap/app/grok-patterns" ]`))
+			Expect(out).To(ContainSubstring(`match => { "message" => "%{CF_LOG_DRAIN}" }`))
+			Expect(grokPattern("cf-log-drain", "CF_LOG_DRAIN")).NotTo(BeEmpty())
+		})
+
+		It("renders the gorouter filter", func() {
+			out := renderTwice("cf-filter-gorouter.conf", map[string]string{"TEMPLATE_ID": "cf-filter-gorouter"})
+			Expect(out).To(ContainSubstring(`id => "cf-filter-gorouter"`))
+			Expect(out).To(ContainSubstring(`patterns_dir => [ "/home/vcap/app/grok-patterns" ]`))
+			Expect(out).To(ContainSubstring(`match => { "message" => "%{CF_GOROUTER_ACCESS}" }`))
+			Expect(grokPattern("cf-gorouter", "CF_GOROUTER_ACCESS")).NotTo(BeEmpty())
+		})
+
+		It("renders the JSON filter with the target of the params", func() {
+			out := renderTwice("cf-filter-json.conf", map[string]string{"TEMPLATE_ID": "cf-filter-json", "PARAM_TARGET": "payload"})
+			Expect(out).To(ContainSubstring(`id => "cf-filter-json"`))
+			Expect(out).To(ContainSubstring(`target => "payload"`))
+		})
+
+		It("renders the Loggregator filter", func() {
+			out := renderTwice("cf-filter-loggregator.conf", map[string]string{"TEMPLATE_ID": "cf-filter-loggregator"})
+			Expect(out).To(ContainSubstring(`id => "cf-filter-loggregator"`))
+			Expect(out).To(ContainSubstring(`patterns_dir => [ "/home/vcap/app/grok-patterns" ]`))
+			Expect(out).To(ContainSubstring(`match => { "message" => "%{CF_LOGGREGATOR_TAGS}" }`))
+			Expect(grokPattern("cf-loggregator", "CF_LOGGREGATOR_TAGS")).NotTo(BeEmpty())
+		})
+
+		It("renders the Elasticsearch output as stdout without service instance", func() {
+			out := renderTwice("cf-output-elasticsearch.conf", map[string]string{"TEMPLATE_ID": "cf-output-elasticsearch"})
+			Expect(out).To(ContainSubstring("stdout { codec => rubydebug }"))
+			Expect(out).NotTo(ContainSubstring("elasticsearch {"))
+		})
+	})
+})
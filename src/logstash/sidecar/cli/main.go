@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"logstash/sidecar"
+)
+
+func main() {
+	logger := log.New(os.Stdout, "[sidecar] ", log.LstdFlags)
+
+	port := os.Getenv("LS_SIDECAR_PORT")
+	inputPort := os.Getenv("LS_HEALTH_CHECK_INPUT_PORT")
+	apiPort := os.Getenv("LS_API_PORT")
+	if port == "" || inputPort == "" || apiPort == "" {
+		logger.Println("LS_SIDECAR_PORT, LS_HEALTH_CHECK_INPUT_PORT and LS_API_PORT must be set")
+		os.Exit(1)
+	}
+
+	pipelines := []string{}
+	for _, id := range strings.Split(os.Getenv("LS_PIPELINE_IDS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			pipelines = append(pipelines, id)
+		}
+	}
+
+	s, err := sidecar.New(fmt.Sprintf("http://127.0.0.1:%s", apiPort), fmt.Sprintf("http://127.0.0.1:%s", inputPort), pipelines)
+	if err != nil {
+		logger.Println(err.Error())
+		os.Exit(2)
+	}
+
+	logger.Printf("listening on port %s, %s checks the Logstash node API on port %s, forwarding to port %s", port, sidecar.HealthPath, apiPort, inputPort)
+	if err := http.ListenAndServe(":"+port, s); err != nil {
+		logger.Println(err.Error())
+		os.Exit(3)
+	}
+}
@@ -0,0 +1,112 @@
+// Package sidecar implements the process which owns $PORT of the Logstash app:
+// it serves the health check endpoint and forwards all other requests to a
+// Logstash input listening on an inner port.
+package sidecar
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// HealthPath is the path of the health check endpoint.
+const HealthPath = "/health"
+
+// Status is the response of the health check endpoint.
+type Status struct {
+	Ready     bool     `json:"ready"`
+	Message   string   `json:"message,omitempty"`
+	Pipelines []string `json:"pipelines"`
+}
+
+// A Sidecar is a http.Handler serving the health check endpoint and
+// forwarding all other requests to the Logstash input.
+type Sidecar struct {
+	APIURL    *url.URL
+	Pipelines []string
+	Client    *http.Client
+	proxy     http.Handler
+}
+
+// New returns a Sidecar checking the Logstash node API at apiURL for the given
+// pipelines and forwarding to the Logstash input at inputURL.
+func New(apiURL string, inputURL string, pipelines []string) (*Sidecar, error) {
+	api, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	input, err := url.Parse(inputURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sidecar{
+		APIURL:    api,
+		Pipelines: pipelines,
+		Client:    &http.Client{Timeout: 5 * time.Second},
+		proxy:     httputil.NewSingleHostReverseProxy(input),
+	}, nil
+}
+
+func (s *Sidecar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != HealthPath {
+		s.proxy.ServeHTTP(w, r)
+		return
+	}
+
+	status := s.Health()
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// Health queries the node stats of Logstash. Logstash is ready if the node
+// API answers and all expected pipelines are running.
+func (s *Sidecar) Health() Status {
+	status := Status{Pipelines: []string{}}
+
+	resp, err := s.Client.Get(s.APIURL.ResolveReference(&url.URL{Path: "/_node/stats/pipelines"}).String())
+	if err != nil {
+		status.Message = fmt.Sprintf("Logstash node API not available: %s", err.Error())
+		return status
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		status.Message = fmt.Sprintf("Logstash node API answered with status %d", resp.StatusCode)
+		return status
+	}
+
+	stats := struct {
+		Pipelines map[string]interface{} `json:"pipelines"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		status.Message = fmt.Sprintf("Unable to read the Logstash node stats: %s", err.Error())
+		return status
+	}
+
+	for id := range stats.Pipelines {
+		status.Pipelines = append(status.Pipelines, id)
+	}
+	sort.Strings(status.Pipelines)
+
+	if len(status.Pipelines) == 0 {
+		status.Message = "no pipeline is running"
+		return status
+	}
+	for _, id := range s.Pipelines {
+		if _, ok := stats.Pipelines[id]; !ok {
+			status.Message = fmt.Sprintf("pipeline %q is not running", id)
+			return status
+		}
+	}
+
+	status.Ready = true
+	return status
+}
@@ -0,0 +1,13 @@
+package sidecar_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSidecar(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sidecar Suite")
+}
@@ -0,0 +1,110 @@
+package sidecar_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"logstash/sidecar"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sidecar", func() {
+	var (
+		api       *httptest.Server
+		input     *httptest.Server
+		stats     string
+		pipelines []string
+		s         *sidecar.Sidecar
+	)
+
+	BeforeEach(func() {
+		stats = `{"pipelines": {"main": {"events": {"in": 0}}}}`
+		pipelines = []string{"main"}
+
+		api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/_node/stats/pipelines" || stats == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, stats)
+		}))
+		input = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "input %s %s", r.Method, r.URL.Path)
+		}))
+	})
+
+	AfterEach(func() {
+		api.Close()
+		input.Close()
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		s, err = sidecar.New(api.URL, input.URL, pipelines)
+		Expect(err).To(BeNil())
+	})
+
+	health := func() (int, sidecar.Status) {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", sidecar.HealthPath, nil))
+		status := sidecar.Status{}
+		Expect(json.Unmarshal(w.Body.Bytes(), &status)).To(Succeed())
+		return w.Code, status
+	}
+
+	It("reports ready if all pipelines are running", func() {
+		code, status := health()
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(status.Ready).To(BeTrue())
+		Expect(status.Pipelines).To(Equal([]string{"main"}))
+	})
+
+	Context("while a pipeline is not running", func() {
+		BeforeEach(func() {
+			pipelines = []string{"main", "kafka"}
+		})
+
+		It("reports not ready", func() {
+			code, status := health()
+			Expect(code).To(Equal(http.StatusServiceUnavailable))
+			Expect(status.Ready).To(BeFalse())
+			Expect(status.Message).To(Equal(`pipeline "kafka" is not running`))
+		})
+	})
+
+	Context("while no pipeline is running", func() {
+		BeforeEach(func() {
+			stats = `{"pipelines": {}}`
+			pipelines = []string{}
+		})
+
+		It("reports not ready", func() {
+			code, _ := health()
+			Expect(code).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+
+	Context("while the node API is not available", func() {
+		BeforeEach(func() {
+			stats = ""
+		})
+
+		It("reports not ready", func() {
+			code, status := health()
+			Expect(code).To(Equal(http.StatusServiceUnavailable))
+			Expect(status.Message).To(ContainSubstring("status 404"))
+		})
+	})
+
+	It("forwards all other requests to the input", func() {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/events", nil))
+		body, _ := ioutil.ReadAll(w.Body)
+		Expect(string(body)).To(Equal("input POST /events"))
+	})
+})
@@ -2,6 +2,7 @@ package main
 
 import (
 	_ "logstash/hooks"
+	"logstash/redact"
 	"logstash/supply"
 	"os"
 	"time"
@@ -10,7 +11,9 @@ import (
 )
 
 func main() {
-	logger := libbuildpack.NewLogger(os.Stdout)
+	// credentials must not show up in the staging log
+	redactor := redact.New(append(redact.ServiceSecrets(os.Getenv("VCAP_SERVICES")), redact.EnvSecrets(os.Environ())...)...)
+	logger := libbuildpack.NewLogger(redactor.Writer(os.Stdout))
 
 	buildpackDir, err := libbuildpack.GetBuildpackDir()
 	if err != nil {
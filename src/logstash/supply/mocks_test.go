@@ -5,7 +5,7 @@
 package supply_test
 
 import (
-	libbuildpack "github.com/cloudfoundry/libbuildpack"
+	libbuildpack "github.com/andibrunner/libbuildpack"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
 )
@@ -70,6 +70,18 @@ func (mr *MockManifestMockRecorder) InstallDependency(arg0, arg1 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InstallDependency", reflect.TypeOf((*MockManifest)(nil).InstallDependency), arg0, arg1)
 }
 
+// InstallDependencyWithCache mocks base method
+func (m *MockManifest) InstallDependencyWithCache(arg0 libbuildpack.Dependency, arg1, arg2 string) error {
+	ret := m.ctrl.Call(m, "InstallDependencyWithCache", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InstallDependencyWithCache indicates an expected call of InstallDependencyWithCache
+func (mr *MockManifestMockRecorder) InstallDependencyWithCache(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InstallDependencyWithCache", reflect.TypeOf((*MockManifest)(nil).InstallDependencyWithCache), arg0, arg1, arg2)
+}
+
 // InstallOnlyVersion mocks base method
 func (m *MockManifest) InstallOnlyVersion(arg0, arg1 string) error {
 	ret := m.ctrl.Call(m, "InstallOnlyVersion", arg0, arg1)
@@ -82,6 +94,18 @@ func (mr *MockManifestMockRecorder) InstallOnlyVersion(arg0, arg1 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InstallOnlyVersion", reflect.TypeOf((*MockManifest)(nil).InstallOnlyVersion), arg0, arg1)
 }
 
+// IsCached mocks base method
+func (m *MockManifest) IsCached() bool {
+	ret := m.ctrl.Call(m, "IsCached")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsCached indicates an expected call of IsCached
+func (mr *MockManifestMockRecorder) IsCached() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsCached", reflect.TypeOf((*MockManifest)(nil).IsCached))
+}
+
 // MockStager is a mock of Stager interface
 type MockStager struct {
 	ctrl     *gomock.Controller
@@ -129,6 +153,18 @@ func (mr *MockStagerMockRecorder) BuildDir() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuildDir", reflect.TypeOf((*MockStager)(nil).BuildDir))
 }
 
+// CacheDir mocks base method
+func (m *MockStager) CacheDir() string {
+	ret := m.ctrl.Call(m, "CacheDir")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// CacheDir indicates an expected call of CacheDir
+func (mr *MockStagerMockRecorder) CacheDir() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CacheDir", reflect.TypeOf((*MockStager)(nil).CacheDir))
+}
+
 // DepDir mocks base method
 func (m *MockStager) DepDir() string {
 	ret := m.ctrl.Call(m, "DepDir")
@@ -10,9 +10,19 @@ import (
 	"io/ioutil"
 	conf "logstash/config"
 
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"io"
+	"logstash/memory"
+	"logstash/pipeline"
+	"logstash/render"
 	"logstash/util"
 	"os/exec"
+	"runtime"
+	"sort"
+
+	"gopkg.in/yaml.v2"
 )
 
 type Manifest interface {
@@ -42,9 +52,6 @@ type Supplier struct {
 	BuildpackDir         string
 	CachedDeps           map[string]string
 	DepCacheDir			 string
-	GTE                  Dependency
-	Jq                   Dependency
-	Ofelia               Dependency
 	Curator              Dependency
 	OpenJdk              Dependency
 	Logstash             Dependency
@@ -58,6 +65,7 @@ type Supplier struct {
 	CuratorFilesExists   bool
 	TemplatesToInstall   []conf.Template
 	PluginsToInstall     map[string]string
+	RemovedPlugins       []string
 }
 
 type Dependency struct {
@@ -88,6 +96,12 @@ func Run(gs *Supplier) error {
 		os.Setenv("BP_DEBUG", "true")
 	}
 
+	//Show effective configuration
+	if err := gs.ShowEffectiveConfig(); err != nil {
+		gs.Log.Error("Unable to show effective configuration: %s", err.Error())
+		return err
+	}
+
 	//Init Cache
 	if err := gs.ReadCachedDependencies(); err != nil {
 		return err
@@ -118,16 +132,7 @@ func Run(gs *Supplier) error {
 	}
 
 	//Install Dependencies
-	if err := gs.InstallDependencyGTE(); err != nil {
-		return err
-	}
-	if err := gs.InstallDependencyJq(); err != nil {
-		return err
-	}
 	if gs.LogstashConfig.Curator.Install {
-		if err := gs.InstallDependencyOfelia(); err != nil {
-			return err
-		}
 		if err := gs.InstallDependencyCurator(); err != nil {
 			return err
 		}
@@ -154,7 +159,7 @@ func Run(gs *Supplier) error {
 		return err
 	}
 
-	//Install Curator/Ofelia
+	//Install Curator
 	if err := gs.PrepareCurator(); err != nil {
 		return err
 	}
@@ -164,6 +169,9 @@ func Run(gs *Supplier) error {
 		return err
 	}
 
+	//Skip plugins of the templates which are bundled with Logstash
+	gs.RemoveBundledPlugins()
+
 	//Install Logstash Plugins
 	if len(gs.PluginsToInstall) > 0 { // there are plugins to install
 
@@ -192,11 +200,22 @@ func Run(gs *Supplier) error {
 		}
 	}
 
+	//Remove Logstash Plugins (remove-plugins and minimal mode)
+	if err := gs.RemoveLogstashPlugins(); err != nil {
+		return err
+	}
+
 	//Install Logstash Plugins
 	if err := gs.ListLogstashPlugins(); err != nil {
 		return err
 	}
 
+	//Prepare Logstash settings (logstash.yml, pipelines.yml)
+	if err := gs.PrepareLogstashSettings(); err != nil {
+		gs.Log.Error("Unable to prepare Logstash settings: %s", err.Error())
+		return err
+	}
+
 	//check Logstash config
 	if gs.LogstashConfig.ConfigCheck {
 		if err := gs.CheckLogstash(); err != nil {
@@ -208,9 +227,13 @@ func Run(gs *Supplier) error {
 	// Remove orphand dependencies from application cache
 	gs.RemoveUnusedDependencies()
 
+	gs.ShowStagingSummary()
+
 	//WriteConfigYml
-	config := map[string]string{
+	config := map[string]interface{}{
 		"LogstashVersion": gs.Logstash.Version,
+		"Pipelines":       gs.LogstashConfig.Pipelines,
+		"Settings":        gs.LogstashConfig.Settings,
 	}
 
 	if err := gs.Stager.WriteConfigYml(config); err != nil {
@@ -235,21 +258,57 @@ func (gs *Supplier) EvalTestCache() error {
 	return nil
 }
 
+func (gs *Supplier) ShowEffectiveConfig() error {
+
+	if strings.ToLower(gs.LogstashConfig.Buildpack.LogLevel) == "debug" {
+		data, err := yaml.Marshal(gs.LogstashConfig)
+		if err != nil {
+			return err
+		}
+		gs.Log.Debug("----> Effective configuration (environment, Logstash file and defaults):")
+		for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+			gs.Log.Debug("        %s", line)
+		}
+	}
+	return nil
+}
+
+// ShowStagingSummary logs the installed templates with their services and
+// the fallback templates installed instead of templates without service.
+func (gs *Supplier) ShowStagingSummary() {
+	gs.Log.Info("----> Staging summary:")
+	gs.Log.Info("        Logstash version: %s", gs.Logstash.Version)
+	if len(gs.TemplatesToInstall) == 0 {
+		gs.Log.Info("        Templates: none")
+	} else {
+		gs.Log.Info("        Templates:")
+	}
+	for _, t := range gs.TemplatesToInstall {
+		switch {
+		case t.FallbackFor != "":
+			gs.Log.Warning("          %s (fallback for %s, no service found)", t.ID(), t.FallbackFor)
+		case t.ServiceInstanceName != "":
+			gs.Log.Info("          %s (service %s)", t.ID(), t.ServiceInstanceName)
+		default:
+			gs.Log.Info("          %s", t.ID())
+		}
+	}
+	if len(gs.PluginsToInstall) > 0 {
+		plugins := []string{}
+		for name := range gs.PluginsToInstall {
+			plugins = append(plugins, name)
+		}
+		sort.Strings(plugins)
+		gs.Log.Info("        Plugins: %s", strings.Join(plugins, ", "))
+	}
+	if len(gs.RemovedPlugins) > 0 {
+		gs.Log.Info("        Removed plugins: %s", strings.Join(gs.RemovedPlugins, ", "))
+	}
+}
+
 func (gs *Supplier) EvalLogstashFile() error {
-	const configCheck = false
-	const reservedMemory  = 300
-	const heapPersentage = 90
-	const logLevel = "Info"
-	const noCache = false
-	const curatorInstall = false
-
-	gs.LogstashConfig = conf.LogstashConfig{
-		Set:            true,
-		ConfigCheck:    configCheck,
-		ReservedMemory: reservedMemory,
-		HeapPercentage: heapPersentage,
-		Curator:        conf.Curator{Set: true, Install: curatorInstall},
-		Buildpack:      conf.Buildpack{Set: true, LogLevel: logLevel, NoCache: noCache}}
+
+	gs.LogstashConfig = conf.LogstashConfig{}
 
 	logstashFile := filepath.Join(gs.Stager.BuildDir(), "Logstash")
 
@@ -257,21 +316,41 @@ func (gs *Supplier) EvalLogstashFile() error {
 	if err != nil {
 		return err
 	}
+	warnings, err := conf.ValidateLogstashFile(data, gs.ContainerMemoryLimit())
+	for _, w := range warnings {
+		gs.Log.Warning("Logstash file, %s", w.Error())
+	}
+	if err != nil {
+		return err
+	}
 	if err := gs.LogstashConfig.Parse(data); err != nil {
 		return err
 	}
 
-	if !gs.LogstashConfig.Set {
-		gs.LogstashConfig.HeapPercentage = heapPersentage
-		gs.LogstashConfig.ReservedMemory = reservedMemory
-		gs.LogstashConfig.ConfigCheck = configCheck
+	//environment variables (LS_BP_*) take precedence over the Logstash file
+	overrides, err := gs.LogstashConfig.ApplyEnvOverrides(os.LookupEnv)
+	if err != nil {
+		return err
 	}
-	if !gs.LogstashConfig.Curator.Set {
-		gs.LogstashConfig.Curator.Install = curatorInstall //not really needed but maybe we will switch to true later
+	if err := conf.ValidateOverrides(&gs.LogstashConfig, overrides, gs.ContainerMemoryLimit()); err != nil {
+		return err
 	}
-	if !gs.LogstashConfig.Buildpack.Set {
-		gs.LogstashConfig.Buildpack.LogLevel = logLevel
-		gs.LogstashConfig.Buildpack.NoCache = noCache
+	for _, o := range overrides {
+		gs.Log.Info("----> Setting '%s' overridden by environment variable %s", o.Key, o.Variable)
+	}
+
+	//settings neither defined in the Logstash file nor overridden get the documented defaults
+	gs.LogstashConfig.ApplyDefaults()
+
+	//the Logstash settings are checked against the selected Logstash version
+	if len(gs.LogstashConfig.Settings) > 0 {
+		version, err := gs.SelectDependencyVersion(Dependency{Name: "logstash", VersionParts: 3, ConfigVersion: gs.LogstashConfig.Version})
+		if err != nil {
+			return err
+		}
+		if err := conf.ValidateSettings(&gs.LogstashConfig, version, conf.LocateKeys(data), overrides); err != nil {
+			return err
+		}
 	}
 
 	/*	//Eval X-Pack
@@ -287,14 +366,9 @@ func (gs *Supplier) EvalLogstashFile() error {
 
 		}
 	*/
-	//ToDo Eval values
-	if gs.LogstashConfig.Curator.Schedule == "" {
-		gs.LogstashConfig.Curator.Schedule = "@daily"
-	}
-
 	//copy the user defined plugins to the PluginsToInstall map
-	for i := 0; i < len(gs.LogstashConfig.Plugins); i++ {
-		gs.PluginsToInstall[gs.LogstashConfig.Plugins[i]] = ""
+	for _, p := range gs.LogstashConfig.Plugins {
+		gs.PluginsToInstall[p.Name] = p.Version
 	}
 
 	return nil
@@ -345,8 +419,15 @@ func (gs *Supplier) PrepareAppDirStructure() error {
 		return err
 	}
 
-	//create dir ofelia in DepDir
-	dir = filepath.Join(gs.Stager.DepDir(), "ofelia")
+	//create dir pipelines in DepDir
+	dir = filepath.Join(gs.Stager.DepDir(), "pipelines")
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	//create dir config (Logstash settings) in DepDir
+	dir = filepath.Join(gs.Stager.DepDir(), "config")
 	err = os.MkdirAll(dir, 0755)
 	if err != nil {
 		return err
@@ -355,30 +436,51 @@ func (gs *Supplier) PrepareAppDirStructure() error {
 	return nil
 }
 
+// EvalTemplatesFile reads the templates of the buildpack and merges the
+// templates of the app (templates/templates.yml) into them. Templates of the
+// app replace templates of the buildpack with the same name.
 func (gs *Supplier) EvalTemplatesFile() error {
 
-	const credHostField = "host"
-	const credUsernameField = "username"
-	const credPasswordField = "password"
-
-	gs.TemplatesConfig = conf.TemplatesConfig{
-		Set:            true,
-		Alias:        conf.Alias{Set: true, CredentialsHostField: credHostField, CredentialsUsernameField: credUsernameField, CredentialsPasswordField: credPasswordField},
-    }
-	templateFile := filepath.Join(gs.BPDir(), "defaults/templates/templates.yml")
+	gs.TemplatesConfig = conf.TemplatesConfig{}
+	templateDir := filepath.Join(gs.BPDir(), "defaults/templates")
 
-	data, err := ioutil.ReadFile(templateFile)
+	data, err := ioutil.ReadFile(filepath.Join(templateDir, "templates.yml"))
 	if err != nil {
 		return err
 	}
 	if err := gs.TemplatesConfig.Parse(data); err != nil {
 		return err
 	}
-	if !gs.TemplatesConfig.Alias.Set {
-		gs.TemplatesConfig.Alias.CredentialsHostField = credHostField
-		gs.TemplatesConfig.Alias.CredentialsUsernameField = credUsernameField
-		gs.TemplatesConfig.Alias.CredentialsPasswordField = credPasswordField
+	gs.TemplatesConfig.SetDir(templateDir)
+
+	appTemplateDir := filepath.Join(gs.Stager.BuildDir(), "templates")
+	appTemplateFile := filepath.Join(appTemplateDir, "templates.yml")
+	if exists, err := libbuildpack.FileExists(appTemplateFile); err != nil {
+		return err
+	} else if exists {
+		data, err := ioutil.ReadFile(appTemplateFile)
+		if err != nil {
+			return err
+		}
+		appTemplates := conf.TemplatesConfig{}
+		if err := appTemplates.Parse(data); err != nil {
+			gs.Log.Error("Unable to parse templates/templates.yml of the app: %s", err.Error())
+			return err
+		}
+		appTemplates.SetDir(appTemplateDir)
+
+		for _, t := range appTemplates.Templates {
+			if exists, err := libbuildpack.FileExists(filepath.Join(appTemplateDir, t.Name+".conf")); err != nil {
+				return err
+			} else if !exists {
+				gs.Log.Error("Template %s of templates/templates.yml has no config file templates/%s.conf", t.Name, t.Name)
+				return errors.New("missing config file of app template")
+			}
+			gs.Log.Info("----> Using template %s of the app", t.Name)
+		}
+		gs.TemplatesConfig.Merge(appTemplates)
 	}
+	gs.TemplatesConfig.Alias.ApplyDefaults()
 
 	return nil
 }
@@ -432,75 +534,6 @@ func (gs *Supplier) EvalEnvironment() error {
 	return nil
 }
 
-func (gs *Supplier) InstallDependencyGTE() error {
-	var err error
-
-	gs.GTE, err = gs.NewDependency("gte", 3, "")
-	if err != nil {
-		return err
-	}
-
-	if err := gs.InstallDependency(gs.GTE); err != nil {
-		return err
-	}
-
-	content := util.TrimLines(fmt.Sprintf(`
-				export GTE_HOME=$DEPS_DIR/%s
-				PATH=$PATH:$GTE_HOME
-				`, gs.GTE.RuntimeLocation))
-
-	if err := gs.WriteDependencyProfileD(gs.GTE.Name, content); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (gs *Supplier) InstallDependencyJq() error {
-	var err error
-
-	gs.Jq, err = gs.NewDependency("jq", 3, "")
-	if err != nil {
-		return err
-	}
-
-	if err := gs.InstallDependency(gs.Jq); err != nil {
-		return err
-	}
-
-	content := util.TrimLines(fmt.Sprintf(`
-				export JQ_HOME=$DEPS_DIR/%s
-				PATH=$PATH:$JQ_HOME
-				`, gs.Jq.RuntimeLocation))
-
-	if err := gs.WriteDependencyProfileD(gs.Jq.Name, content); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (gs *Supplier) InstallDependencyOfelia() error {
-	var err error
-	gs.Ofelia, err = gs.NewDependency("ofelia", 3, "")
-	if err != nil {
-		return err
-	}
-
-	if err := gs.InstallDependency(gs.Ofelia); err != nil {
-		return err
-	}
-
-	content := util.TrimLines(fmt.Sprintf(`
-				export OFELIA_HOME=$DEPS_DIR/%s
-				PATH=$PATH:$OFELIA_HOME
-				`, gs.Ofelia.RuntimeLocation))
-
-	if err := gs.WriteDependencyProfileD(gs.Ofelia.Name, content); err != nil {
-		return err
-	}
-	return nil
-}
-
 func (gs *Supplier) InstallDependencyCurator() error {
 
 	var err error
@@ -534,7 +567,7 @@ func (gs *Supplier) PrepareCurator() error {
 				export LC_ALL=en_US.UTF-8
 				export LANG=en_US.UTF-8
 				export PATH=${CURATOR_HOME}/python3/bin:${CURATOR_HOME}/curator/bin:${PATH}
-				${CURATOR_HOME}/python3/bin/python3 ${CURATOR_HOME}/curator/bin/curator --config ${HOME}/curator.d/curator.yml ${HOME}/curator.d/actions.yml
+				${CURATOR_HOME}/python3/bin/python3 ${CURATOR_HOME}/curator/bin/curator --config ${HOME}/curator.conf.d/curator.yml ${HOME}/curator.conf.d/actions.yml
 				`))
 
 	err := ioutil.WriteFile(filepath.Join(gs.Stager.DepDir(), "curator", "curator.sh"), []byte(content), 0755)
@@ -543,27 +576,13 @@ func (gs *Supplier) PrepareCurator() error {
 		return err
 	}
 
-	//create Curator start script
-	content = util.TrimLines(fmt.Sprintf(`
-				[job-local "curator"]
-				schedule = %s
-				command = {{- .Env.HOME -}}/bin/curator.sh
-				`,
-		gs.LogstashConfig.Curator.Schedule))
-
-	err = ioutil.WriteFile(filepath.Join(gs.Stager.DepDir(), "ofelia", "schedule.ini"), []byte(content), 0644)
-	if err != nil {
-		gs.Log.Error("Unable to create Ofelia schedule.ini: %s", err.Error())
-		return err
-	}
-
 	// pre-processing of curator config templates if no user files exist
 	if !gs.CuratorFilesExists {
 
 		templateFile := filepath.Join(gs.BPDir(), "defaults/curator")
 		destFile := filepath.Join(gs.Stager.DepDir(), "curator.d")
 
-		err := exec.Command(fmt.Sprintf("%s/gte", gs.GTE.StagingLocation), "-d", "<<:>>", templateFile, destFile).Run()
+		err := render.New(render.StagingDelims).RenderDir(templateFile, destFile)
 		if err != nil {
 			gs.Log.Error("Error pre-processing curator config templates: %s", err.Error())
 			return err
@@ -649,24 +668,44 @@ func (gs *Supplier) InstallLogstash() error {
 		sleepCommand = "yes"
 	}
 
+	healthCheckEnabled := ""
+	if gs.LogstashConfig.HealthCheck.Enabled {
+		healthCheckEnabled = "enabled"
+	}
+
+	pipelineIDs := gs.PipelineIDs()
+	if len(pipelineIDs) == 0 {
+		pipelineIDs = []string{conf.DefaultPipelineID}
+	}
+
 	content := util.TrimLines(fmt.Sprintf(`
-			export LS_BP_RESERVED_MEMORY=%d
-			export LS_BP_HEAP_PERCENTAGE=%d
-			export LS_BP_JAVA_OPTS=%s
+			export LS_RESERVED_MEMORY=%d
+			export LS_HEAP_PERCENTAGE=%d
+			export LS_USER_JAVA_OPTS=%s
 			export LS_CMD_ARGS=%s
 			export LS_ROOT=$DEPS_DIR/%s
 			export LS_CURATOR_ENABLED=%s
+			export LS_CURATOR_SCHEDULE='%s'
 			export LS_DO_SLEEP=%s
+			export LS_HEALTH_CHECK_ENABLED=%s
+			export LS_HEALTH_CHECK_INPUT_PORT=%d
+			export LS_API_PORT=%d
+			export LS_PIPELINE_IDS=%s
 			export LOGSTASH_HOME=$DEPS_DIR/%s
 			PATH=$PATH:$LOGSTASH_HOME/bin
 			`,
 		gs.LogstashConfig.ReservedMemory,
 		gs.LogstashConfig.HeapPercentage,
-		gs.LogstashConfig.JavaOpts,
-		gs.LogstashConfig.CmdArgs,
+		util.ShellQuote(gs.LogstashConfig.JavaOpts),
+		util.ShellQuote(gs.LogstashConfig.CmdArgs),
 		gs.Stager.DepsIdx(),
 		curatorEnabled,
+		gs.LogstashConfig.Curator.Schedule,
 		sleepCommand,
+		healthCheckEnabled,
+		gs.LogstashConfig.HealthCheck.InputPort,
+		gs.LogstashConfig.APIPort(),
+		strings.Join(pipelineIDs, ","),
 		gs.Logstash.RuntimeLocation))
 
 	if err := gs.WriteDependencyProfileD(gs.Logstash.Name, content); err != nil {
@@ -676,16 +715,173 @@ func (gs *Supplier) InstallLogstash() error {
 	return nil
 }
 
-func (gs *Supplier) PrepareStagingEnvironment() error {
-	vmOptions := gs.LogstashConfig.JavaOpts
+// PrepareLogstashSettings creates the settings directory of Logstash
+// ($LS_ROOT/config, used with --path.settings) from the config of the Logstash
+// distribution and renders the logstash.yml (queue settings and the settings
+// section of the Logstash file) and, for multiple pipelines, the
+// pipelines.yml.
+func (gs *Supplier) PrepareLogstashSettings() error {
+	settingsDir := filepath.Join(gs.Stager.DepDir(), "config")
 
-	if vmOptions != "" {
-		os.Setenv("LS_JAVA_OPTS", fmt.Sprintf("%s", vmOptions))
-	} else {
-		mem := (gs.VcapApp.Limits.Mem - gs.LogstashConfig.ReservedMemory) / 100 * gs.LogstashConfig.HeapPercentage
-		os.Setenv("LS_JAVA_OPTS", fmt.Sprintf("-Xmx%dm -Xms%dm", mem, mem))
+	if err := libbuildpack.CopyDirectory(filepath.Join(gs.Logstash.StagingLocation, "config"), settingsDir); err != nil {
+		return err
+	}
+
+	queueSettings, err := gs.QueueSettings()
+	if err != nil {
+		return err
+	}
+	settings := gs.MergeSettings(queueSettings, conf.FlattenSettings(gs.LogstashConfig.Settings))
+
+	if len(settings) > 0 {
+		data, err := yaml.Marshal(settings)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(settingsDir, "logstash.yml"), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	if len(gs.LogstashConfig.Pipelines) == 0 {
+		return nil
+	}
+
+	pipelines := []yaml.MapSlice{}
+	for _, p := range gs.LogstashConfig.Pipelines {
+		pipeline := yaml.MapSlice{
+			{Key: "pipeline.id", Value: p.ID},
+			{Key: "path.config", Value: filepath.Join("logstash.pipelines.d", p.ID)},
+		}
+		if p.Workers > 0 {
+			pipeline = append(pipeline, yaml.MapItem{Key: "pipeline.workers", Value: p.Workers})
+		}
+		if p.BatchSize > 0 {
+			pipeline = append(pipeline, yaml.MapItem{Key: "pipeline.batch.size", Value: p.BatchSize})
+		}
+		if p.QueueType != "" {
+			pipeline = append(pipeline, yaml.MapItem{Key: "queue.type", Value: strings.ToLower(p.QueueType)})
+		}
+		pipelines = append(pipelines, pipeline)
 	}
 
+	data, err := yaml.Marshal(pipelines)
+	if err != nil {
+		return err
+	}
+
+	gs.Log.Info("----> Rendering pipelines.yml for pipelines %s", strings.Join(gs.PipelineIDs(), ", "))
+	return ioutil.WriteFile(filepath.Join(settingsDir, "pipelines.yml"), data, 0644)
+}
+
+// MergeSettings returns the settings derived by the buildpack followed by the
+// settings of the Logstash file. Settings of the Logstash file take precedence.
+func (gs *Supplier) MergeSettings(derived yaml.MapSlice, settings map[string]interface{}) yaml.MapSlice {
+	merged := yaml.MapSlice{}
+	for _, item := range derived {
+		if value, ok := settings[fmt.Sprint(item.Key)]; ok {
+			gs.Log.Warning("Setting '%s: %v' of the Logstash file replaces '%v' derived by the buildpack", item.Key, value, item.Value)
+			continue
+		}
+		merged = append(merged, item)
+	}
+	for _, key := range conf.SortedKeys(settings) {
+		merged = append(merged, yaml.MapItem{Key: key, Value: settings[key]})
+	}
+	return merged
+}
+
+// QueueSettings returns the Logstash settings for persisted queues and dead
+// letter queues. Sizes not defined in the Logstash file are derived from the
+// disk quota of the app.
+func (gs *Supplier) QueueSettings() (yaml.MapSlice, error) {
+	settings := yaml.MapSlice{}
+
+	queues := gs.LogstashConfig.PersistedQueues()
+	deadLetterQueues := gs.LogstashConfig.DeadLetterQueues()
+	if queues == 0 && deadLetterQueues == 0 {
+		return settings, nil
+	}
+
+	disk := 0
+	if gs.VcapApp.Limits != nil {
+		disk = gs.VcapApp.Limits.Disk
+	}
+
+	// the droplet (Logstash, JDK, plugins, app) is stored on the same disk as the queues
+	used := 0
+	for _, dir := range []string{gs.Stager.BuildDir(), gs.Stager.DepDir()} {
+		size, err := util.DirSize(dir)
+		if err != nil {
+			return settings, err
+		}
+		used += int(size / 1024 / 1024)
+	}
+
+	sizes := gs.LogstashConfig.QueueSizes(disk, used)
+
+	gs.Log.Info("----> Queues: %d persisted queue(s) with %dmb, %d dead letter queue(s) with %dmb (disk quota %dmb, droplet %dmb)",
+		sizes.Queues, sizes.MaxBytes, sizes.DeadLetterQueues, sizes.DeadLetterQueueMaxBytes, disk, used)
+
+	if disk == 0 {
+		gs.Log.Warning("Disk quota of the app is unknown, the queue sizes can not be derived from it")
+	} else if !sizes.Fits() {
+		gs.Log.Warning("The queues need %dmb but only %dmb of the disk quota (%dmb) are available (droplet %dmb, safety margin %d%%). Please increase the disk quota or reduce the queue sizes", sizes.Required, sizes.Available, disk, used, conf.QueueDiskMarginPercentage)
+	}
+	if queues > 0 && sizes.MaxBytes < conf.MinQueueMaxBytes {
+		gs.Log.Warning("The size of the persisted queues (%dmb) is below the minimum of %dmb. Please increase the disk quota", sizes.MaxBytes, conf.MinQueueMaxBytes)
+	}
+
+	if queues > 0 {
+		if strings.EqualFold(gs.LogstashConfig.Queue.Type, "persisted") {
+			settings = append(settings, yaml.MapItem{Key: "queue.type", Value: "persisted"})
+		}
+		if sizes.MaxBytes > 0 {
+			settings = append(settings, yaml.MapItem{Key: "queue.max_bytes", Value: fmt.Sprintf("%dmb", sizes.MaxBytes)})
+		}
+	}
+	if deadLetterQueues > 0 {
+		settings = append(settings, yaml.MapItem{Key: "dead_letter_queue.enable", Value: true})
+		if sizes.DeadLetterQueueMaxBytes > 0 {
+			settings = append(settings, yaml.MapItem{Key: "dead_letter_queue.max_bytes", Value: fmt.Sprintf("%dmb", sizes.DeadLetterQueueMaxBytes)})
+		}
+	}
+
+	return settings, nil
+}
+
+func (gs *Supplier) PipelineIDs() []string {
+	ids := []string{}
+	for _, p := range gs.LogstashConfig.Pipelines {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func (gs *Supplier) PrepareStagingEnvironment() error {
+	//same calculation as by the launcher at startup (with the CPU cores of the staging container)
+	totalMemory := 0
+	if gs.VcapApp.Limits != nil {
+		totalMemory = gs.VcapApp.Limits.Mem
+	}
+	result, err := memory.Calculate(memory.Input{
+		TotalMemory:    totalMemory,
+		ReservedMemory: gs.LogstashConfig.ReservedMemory,
+		HeapPercentage: gs.LogstashConfig.HeapPercentage,
+		Workers:        gs.LogstashConfig.Workers(runtime.NumCPU()),
+		JavaOpts:       gs.LogstashConfig.JavaOpts,
+	})
+	if err != nil {
+		gs.Log.Error("Unable to calculate the JVM memory with %d pipeline workers: %s", gs.LogstashConfig.Workers(runtime.NumCPU()), err.Error())
+		return err
+	}
+	gs.Log.Info("----> JVM memory: heap %dm, metaspace %dm, %d thread stacks %dm (available %dm)",
+		result.Heap, result.Metaspace, result.Threads, result.Stacks(), result.Available)
+	if result.DirectMemory > 0 {
+		gs.Log.Info("      direct memory limited to %dm", result.DirectMemory)
+	}
+	os.Setenv("LS_JAVA_OPTS", result.String())
+
 	os.Setenv("JAVA_HOME", gs.OpenJdk.StagingLocation)
 	os.Setenv("PATH", os.Getenv("PATH")+":"+gs.OpenJdk.StagingLocation+"/bin")
 	os.Setenv("PORT", "8080") //dummy PORT: used by template processing for logstash check
@@ -711,10 +907,10 @@ func (gs *Supplier) InstallUserCertificates() error {
 		localCert := localCerts[gs.LogstashConfig.Certificates[i]]
 
 		if localCert != "" {
-			gs.Log.Info(fmt.Sprintf("----> installing user certificate '%s' to TrustStore ... ", gs.LogstashConfig.Certificates[i]))
+			gs.Log.Info("----> installing user certificate '%s' to TrustStore ... ", gs.LogstashConfig.Certificates[i])
 			certToInstall := gs.Stager.BuildDir() + "/certificates/" + localCert
 			out, err := exec.Command(fmt.Sprintf("%s/bin/keytool", gs.OpenJdk.StagingLocation), "-import", "-trustcacerts", "-keystore", fmt.Sprintf("%s/jre/lib/security/cacerts", gs.OpenJdk.StagingLocation), "-storepass", "changeit", "-noprompt", "-alias", gs.LogstashConfig.Certificates[i], "-file", certToInstall).CombinedOutput()
-			gs.Log.Info(string(out))
+			gs.Log.Info("%s", out)
 			if err != nil {
 				gs.Log.Warning("Error installing user certificate '%s' to TrustStore: %s", gs.LogstashConfig.Certificates[i], err.Error())
 			}
@@ -731,7 +927,82 @@ func (gs *Supplier) InstallUserCertificates() error {
 
 func (gs *Supplier) InstallTemplates() error {
 
-	if !gs.ConfigFilesExists && len(gs.LogstashConfig.ConfigTemplates) == 0 {
+	if len(gs.LogstashConfig.Pipelines) == 0 {
+		// single pipeline: templates --> conf.d
+		templates, err := gs.SelectTemplates(gs.LogstashConfig.ConfigTemplates, gs.ConfigFilesExists)
+		if err != nil {
+			return err
+		}
+		if err := gs.RenderTemplates(templates, filepath.Join(gs.Stager.DepDir(), "conf.d")); err != nil {
+			return err
+		}
+		gs.TemplatesToInstall = append(gs.TemplatesToInstall, templates...)
+	} else {
+		// multiple pipelines: templates --> pipelines/<id>
+		// there is no automatic mode, only explicitly defined templates are installed
+		if gs.ConfigFilesExists {
+			gs.Log.Error("The config files in 'conf.d' are not used with pipelines, move them into the config-dir of a pipeline")
+			return errors.New("conf.d can not be combined with pipelines")
+		}
+		for _, p := range gs.LogstashConfig.Pipelines {
+			templates, err := gs.SelectTemplates(p.ConfigTemplates, true)
+			if err != nil {
+				return err
+			}
+			if len(templates) == 0 && !gs.PipelineConfigFilesExist(p) {
+				gs.Log.Error("No config files found in '%s' and no config templates defined for pipeline %s", p.ConfigDir, p.ID)
+				return errors.New("no configuration for pipeline")
+			}
+			if err := gs.RenderTemplates(templates, filepath.Join(gs.Stager.DepDir(), "pipelines", p.ID)); err != nil {
+				return err
+			}
+			gs.TemplatesToInstall = append(gs.TemplatesToInstall, templates...)
+		}
+	}
+
+	// copy grok-patterns and plugins
+	// grok-patterns are taken from the grok-patterns directory next to the templates directory
+	var groksToInstall map[string]string
+
+	groksToInstall = make(map[string]string)
+
+	for i := 0; i < len(gs.TemplatesToInstall); i++ {
+
+		for g := 0; g < len(gs.TemplatesToInstall[i].Groks); g++ {
+			groksToInstall[gs.TemplatesToInstall[i].Groks[g]] = filepath.Join(filepath.Dir(gs.TemplatesToInstall[i].Dir), "grok-patterns")
+		}
+		for p := 0; p < len(gs.TemplatesToInstall[i].Plugins); p++ {
+			// the version of a plugin of the Logstash file is kept
+			if _, ok := gs.PluginsToInstall[gs.TemplatesToInstall[i].Plugins[p]]; !ok {
+				gs.PluginsToInstall[gs.TemplatesToInstall[i].Plugins[p]] = ""
+			}
+		}
+	}
+
+	for key, grokDir := range groksToInstall {
+		grokFile := filepath.Join(grokDir, key)
+		destFile := filepath.Join(gs.Stager.DepDir(), "grok-patterns", key)
+
+		err := render.New(render.StagingDelims).RenderFile(grokFile, destFile)
+		if err != nil {
+			gs.Log.Error("Error pre-processing grok-patterns template %s: %s", key, err.Error())
+			return err
+		}
+	}
+
+	//default Plugins will be installed in method "InstallLogstashPlugins"
+
+	return nil
+}
+
+// SelectTemplates returns the templates to install for the given config
+// templates of the Logstash file. If there are neither config files nor config
+// templates, all default templates are selected (automatic mode).
+func (gs *Supplier) SelectTemplates(configTemplates []conf.ConfigTemplate, configFilesExists bool) ([]conf.Template, error) {
+
+	templatesToInstall := []conf.Template{}
+
+	if !configFilesExists && len(configTemplates) == 0 {
 		// install all default templates
 
 		//copy default templates to config
@@ -739,39 +1010,23 @@ func (gs *Supplier) InstallTemplates() error {
 
 			if t.IsDefault {
 
-				if len(t.Tags) > 0 {
-					vcapServices := []conf.VcapService{}
-					vcapServicesWithTag := gs.VcapServices.WithTags(t.Tags)
-					vcapServicesUserProvided := gs.VcapServices.UserProvided()
-
-					if len(vcapServicesWithTag) > 0 {
-						vcapServices = append(vcapServices, vcapServicesWithTag...)
-					}
-					if len(vcapServicesUserProvided) > 0 {
-						vcapServices = append(vcapServices, vcapServicesUserProvided...)
-					}
-
-					if len(vcapServices) == 0 {
+				values, err := t.ResolveParams(nil)
+				if err != nil {
+					gs.Log.Error("Unable to use default template %s: %s", t.Name, err.Error())
+					return templatesToInstall, err
+				}
+				t.ParamValues = values
 
-						if gs.LogstashConfig.EnableServiceFallback {
-							ti := t
-							ti.ServiceInstanceName = ""
-							gs.TemplatesToInstall = append(gs.TemplatesToInstall, ti)
-							gs.Log.Warning("No service found for template %s, will do the fallback. Please bind a service and restage the app", ti.Name)
-						} else {
-							return errors.New("no service found for template")
-						}
-					} else if len(vcapServices) > 1 {
-						return errors.New("more than one service found for template")
-					} else {
-						ti := t
-						ti.ServiceInstanceName = vcapServices[0].Name
-						gs.TemplatesToInstall = append(gs.TemplatesToInstall, ti)
+				if len(t.Tags) > 0 {
+					bound, err := gs.BindTemplate(t, templatesToInstall)
+					if err != nil {
+						return templatesToInstall, err
 					}
+					templatesToInstall = append(templatesToInstall, bound...)
 				} else {
 					ti := t
 					ti.ServiceInstanceName = ""
-					gs.TemplatesToInstall = append(gs.TemplatesToInstall, ti)
+					templatesToInstall = append(templatesToInstall, ti)
 				}
 			}
 		}
@@ -780,7 +1035,7 @@ func (gs *Supplier) InstallTemplates() error {
 		//only install explicitly defined templates, if any
 		//check them all
 
-		for _, ct := range gs.LogstashConfig.ConfigTemplates {
+		for _, ct := range configTemplates {
 			found := false
 			templateName := strings.Trim(ct.Name, " ")
 			if len(templateName) == 0 {
@@ -790,18 +1045,36 @@ func (gs *Supplier) InstallTemplates() error {
 			for _, t := range gs.TemplatesConfig.Templates {
 				if templateName == t.Name {
 					serviceInstanceName := strings.Trim(ct.ServiceInstanceName, " ")
-					if len(serviceInstanceName) == 0 && len(t.Tags) > 0 {
-						gs.Log.Error("No service instance name defined for template %s in Logstash file", templateName)
-						return errors.New("no service instance name defined for template in Logstash file")
-					}
 
 					ti := t
+					values, err := ti.ResolveParams(ct.Params)
+					if err != nil {
+						gs.Log.Error("Invalid params of template %s in Logstash file: %s", templateName, err.Error())
+						return templatesToInstall, err
+					}
+					ti.ParamValues = values
+
 					if len(serviceInstanceName) > 0 && len(t.Tags) == 0 {
 						gs.Log.Warning("Service instance name '%s' is defined for template %s in Logstash file but template can not be bound to a service.", serviceInstanceName, templateName)
+					} else if len(serviceInstanceName) == 0 && len(t.Tags) > 0 {
+						// bind the template by its tags like in automatic mode
+						bound, err := gs.BindTemplate(ti, templatesToInstall)
+						if err != nil {
+							return templatesToInstall, err
+						}
+						templatesToInstall = append(templatesToInstall, bound...)
+						found = true
+						break
 					} else {
 						ti.ServiceInstanceName = serviceInstanceName
+						credentials, err := gs.detectCredentials(ti)
+						if err != nil {
+							gs.Log.Error("Credentials of service %s do not fit template %s: %s", serviceInstanceName, templateName, err.Error())
+							return templatesToInstall, err
+						}
+						ti.Credentials = credentials
 					}
-					gs.TemplatesToInstall = append(gs.TemplatesToInstall, ti)
+					templatesToInstall = append(templatesToInstall, ti)
 
 					found = true
 					break
@@ -813,61 +1086,150 @@ func (gs *Supplier) InstallTemplates() error {
 		}
 	}
 
-	//copy templates --> conf.d
-	for _, ti := range gs.TemplatesToInstall {
-
-		os.Setenv("SERVICE_INSTANCE_NAME", ti.ServiceInstanceName)
-		os.Setenv("CREDENTIALS_HOST_FIELD", gs.TemplatesConfig.Alias.CredentialsHostField)
-		os.Setenv("CREDENTIALS_USERNAME_FIELD", gs.TemplatesConfig.Alias.CredentialsUsernameField)
-		os.Setenv("CREDENTIALS_PASSWORD_FIELD", gs.TemplatesConfig.Alias.CredentialsPasswordField)
+	return templatesToInstall, nil
+}
 
-		templateFile := filepath.Join(gs.BPDir(), "defaults/templates/", ti.Name+".conf")
-		destFile := filepath.Join(gs.Stager.DepDir(), "conf.d", ti.Name+".conf")
+// BindTemplate binds the tagged template t to the matching service instances
+// (see VcapServices.Candidates and ServiceSelection). It returns the template
+// once per service instance or, with enable-service-fallback, the fallback
+// template of the same type if there is no service instance and the fallback
+// template is not yet in selected.
+func (gs *Supplier) BindTemplate(t conf.Template, selected []conf.Template) ([]conf.Template, error) {
+	candidates := gs.VcapServices.Candidates(t, &gs.TemplatesConfig, gs.LogstashConfig.ServiceSelection)
+	accepted := conf.Accepted(candidates)
+
+	if len(accepted) == 0 {
+		if !gs.LogstashConfig.EnableServiceFallback {
+			gs.Log.Error("No service found for template %s. %s", t.Name, describeCandidates(candidates))
+			return nil, errors.New("no service found for template")
+		}
 
-		err := exec.Command(fmt.Sprintf("%s/gte", gs.GTE.StagingLocation), "-d", "<<:>>", templateFile, destFile).Run()
+		fallback, found := gs.TemplatesConfig.Fallback(t.Type)
+		if !found {
+			gs.Log.Error("No service found for template %s and there is no fallback template of type %s. %s", t.Name, t.Type, describeCandidates(candidates))
+			return nil, errors.New("no fallback template found")
+		}
+		values, err := fallback.ResolveParams(nil)
 		if err != nil {
-			gs.Log.Error("Error pre-processing template %s: %s", ti.Name, err.Error())
-			return err
+			gs.Log.Error("Unable to use fallback template %s: %s", fallback.Name, err.Error())
+			return nil, err
 		}
+		fallback.ParamValues = values
+		fallback.FallbackFor = t.Name
+		gs.Log.Warning("No service found for template %s, installing the fallback template %s instead. Please bind a service and restage the app", t.Name, fallback.Name)
+		if containsTemplate(selected, fallback.Name) {
+			return nil, nil
+		}
+		return []conf.Template{fallback}, nil
+	}
 
+	if len(accepted) > 1 && !gs.LogstashConfig.ServiceSelection.IsAll() {
+		gs.Log.Error("More than one service found for template %s. Please select one with service-selection (label, plan or name) or set service-selection.mode to 'all'. %s", t.Name, describeCandidates(candidates))
+		return nil, errors.New("more than one service found for template")
 	}
 
-	// copy grok-patterns and plugins
-	var groksToInstall map[string]string
+	// one rendering of the template per service instance
+	bound := []conf.Template{}
+	for _, c := range accepted {
+		ti := t
+		ti.ServiceInstanceName = c.Service.Name
+		ti.Credentials = c.Credentials
+		bound = append(bound, ti)
+		gs.Log.Info("----> Template %s bound to service %s (credentials: %s)", ti.Name, ti.ServiceInstanceName, ti.Credentials.Shape)
+	}
+	return bound, nil
+}
 
-	groksToInstall = make(map[string]string)
+// containsTemplate returns true if a template with the given name is in the
+// list.
+func containsTemplate(templates []conf.Template, name string) bool {
+	for _, t := range templates {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
 
-	for i := 0; i < len(gs.TemplatesToInstall); i++ {
+// detectCredentials detects the credentials of the service instance bound to
+// an explicitly defined template. If the service instance is not bound to the
+// app, the fields of the alias are used as they are.
+func (gs *Supplier) detectCredentials(t conf.Template) (conf.Credentials, error) {
+	service, found := gs.VcapServices.ByName(t.ServiceInstanceName)
+	if !found {
+		gs.Log.Warning("Service %s of template %s is not bound to the app", t.ServiceInstanceName, t.Name)
+		alias := gs.TemplatesConfig.AliasFor(t, "")
+		return conf.Credentials{
+			Shape:         conf.CredentialsFields,
+			HostField:     alias.CredentialsHostField,
+			UsernameField: alias.CredentialsUsernameField,
+			PasswordField: alias.CredentialsPasswordField,
+		}, nil
+	}
+	return conf.DetectCredentials(service.Credentials, gs.TemplatesConfig.AliasFor(t, service.Label))
+}
 
-		for g := 0; g < len(gs.TemplatesToInstall[i].Groks); g++ {
-			groksToInstall[gs.TemplatesToInstall[i].Groks[g]] = ""
-		}
-		for p := 0; p < len(gs.TemplatesToInstall[i].Plugins); p++ {
-			gs.PluginsToInstall[gs.TemplatesToInstall[i].Plugins[p]] = ""
-		}
+// describeCandidates lists the service instances evaluated for a template with
+// the reason they have been accepted or rejected.
+func describeCandidates(candidates []conf.Candidate) string {
+	if len(candidates) == 0 {
+		return "No services are bound to the app"
+	}
+	lines := []string{"Services bound to the app:"}
+	for _, c := range candidates {
+		lines = append(lines, "  - "+c.String())
 	}
+	return strings.Join(lines, "\n")
+}
 
-	for key, _ := range groksToInstall {
-		grokFile := filepath.Join(gs.BPDir(), "defaults/grok-patterns", key)
-		destFile := filepath.Join(gs.Stager.DepDir(), "grok-patterns", key)
+// RenderTemplates pre-processes the templates (staging delimiters "<< >>") into
+// destDir, one file per template id (see Template.ID).
+func (gs *Supplier) RenderTemplates(templates []conf.Template, destDir string) error {
 
-		err := exec.Command(fmt.Sprintf("%s/gte", gs.GTE.StagingLocation), "-d", "<<:>>", grokFile, destFile).Run()
-		if err != nil {
-			gs.Log.Error("Error pre-processing grok-patterns template %s: %s", key, err.Error())
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	for _, ti := range templates {
+
+		// the variables of the template are only visible to this template
+		r := render.New(render.StagingDelims)
+		r.Env["SERVICE_INSTANCE_NAME"] = ti.ServiceInstanceName
+		r.Env["TEMPLATE_ID"] = ti.ID()
+		r.Env["CREDENTIALS_SHAPE"] = ti.Credentials.Shape
+		r.Env["CREDENTIALS_HOST_FIELD"] = ti.Credentials.HostField
+		r.Env["CREDENTIALS_USERNAME_FIELD"] = ti.Credentials.UsernameField
+		r.Env["CREDENTIALS_PASSWORD_FIELD"] = ti.Credentials.PasswordField
+
+		for name, value := range ti.ParamValues {
+			r.Env[conf.ParamVariable(name)] = value
+		}
+
+		templateFile := filepath.Join(ti.Dir, ti.Name+".conf")
+		destFile := filepath.Join(destDir, ti.ID()+".conf")
+
+		if err := r.RenderFile(templateFile, destFile); err != nil {
+			gs.Log.Error("Error pre-processing template %s: %s", ti.Name, err.Error())
 			return err
 		}
-	}
 
-	//default Plugins will be installed in method "InstallLogstashPlugins"
+	}
 
 	return nil
 }
 
+// PipelineConfigFilesExist checks if files (also directories) exist in the
+// config directory of the pipeline within the app.
+func (gs *Supplier) PipelineConfigFilesExist(p conf.Pipeline) bool {
+	files, err := ioutil.ReadDir(filepath.Join(gs.Stager.BuildDir(), p.ConfigDir))
+	return err == nil && len(files) > 0
+}
+
 func (gs *Supplier) ListLogstashPlugins() error {
 	gs.Log.Info("----> Listing all installed Logstash plugins ...")
 
 	out, err := exec.Command(fmt.Sprintf("%s/bin/logstash-plugin", gs.Logstash.StagingLocation), "list", "--verbose").CombinedOutput()
-	gs.Log.Info(string(out))
+	gs.Log.Info("%s", out)
 	if err != nil {
 		gs.Log.Error("Error listing all installed Logstash plugins: %s", err.Error())
 		return err
@@ -875,74 +1237,644 @@ func (gs *Supplier) ListLogstashPlugins() error {
 	return nil
 }
 
+// RemoveBundledPlugins removes the plugins of the templates which are already
+// installed in Logstash (e.g. logstash-output-kafka) from the plugins to
+// install. Plugins of the Logstash file are always installed.
+func (gs *Supplier) RemoveBundledPlugins() {
+	explicit := map[string]bool{}
+	for _, plugin := range gs.LogstashConfig.Plugins {
+		explicit[plugin.Name] = true
+	}
+	for key := range gs.PluginsToInstall {
+		if explicit[key] {
+			continue
+		}
+		gems, _ := filepath.Glob(filepath.Join(gs.Logstash.StagingLocation, "vendor", "bundle", "jruby", "*", "gems", key+"-[0-9]*"))
+		if len(gems) > 0 {
+			gs.Log.Debug("Plugin %s is bundled with Logstash", key)
+			delete(gs.PluginsToInstall, key)
+		}
+	}
+}
+
+// InstallLogstashPlugins installs the plugins of PluginsToInstall (name and
+// version constraint) from the first source providing a matching version:
+// x-pack, logstash-plugins, the plugins folder of the app and finally
+// rubygems (online installation, not allowed offline, see IsOffline). The
+// versions of the lock file are preferred as long as they satisfy the
+// constraints, the installed versions are written to the lock file again.
+// The plugins are installed with as few runs of logstash-plugin as possible
+// (see conf.PluginInstalls).
+// The installed plugins are cached, a staging with the same Logstash version
+// and the same resolved plugins restores them without logstash-plugin.
 func (gs *Supplier) InstallLogstashPlugins() error {
 
-	xPackPlugins, _ := gs.ReadLocalPlugins(gs.XPack.StagingLocation)
-	defaultPlugins, _ := gs.ReadLocalPlugins(gs.LogstashPlugins.StagingLocation)
-	userPlugins, _ := gs.ReadLocalPlugins(gs.Stager.BuildDir() + "/plugins")
+	lock := gs.ReadPluginLock()
+	resolved := conf.PluginLock{Logstash: gs.Logstash.Version}
+	constraints := map[string]string{}
+	offline := gs.IsOffline()
+	unresolved := []string{}
 
 	gs.Log.Info("----> Installing Logstash plugins ...")
-	for key, _ := range gs.PluginsToInstall {
-		//Priorisation
-		xpackPlugin := gs.GetLocalPlugin(key, xPackPlugins)
-		defaultPlugin := gs.GetLocalPlugin(key, defaultPlugins)
-		userPlugin := gs.GetLocalPlugin(key, userPlugins)
-
-		pluginToInstall := ""
-
-		if xpackPlugin != "" {
-			pluginToInstall = filepath.Join(gs.XPack.StagingLocation, xpackPlugin) // Prio 1 (offline installation)
-		} else if defaultPlugin != "" {
-			pluginToInstall = filepath.Join(gs.LogstashPlugins.StagingLocation, defaultPlugin) // Prio 2 (offline installation)
-		} else if userPlugin != "" {
-			pluginToInstall = filepath.Join(gs.Stager.BuildDir(), "plugins", userPlugin) // Prio 3 (offline installation)
+	for _, name := range gs.sortedPluginsToInstall() {
+		constraint := gs.PluginsToInstall[name]
+
+		locked, isLocked := lock.Find(name)
+		if isLocked && !conf.SatisfiesVersion(locked.Version, constraint) {
+			gs.Log.Info("       Locked version %s of plugin %s does not satisfy %q, resolving it again", locked.Version, name, constraint)
+			isLocked = false
+		}
+		if isLocked {
+			constraint = locked.Version
+		}
+
+		plugin, err := gs.ResolvePlugin(name, constraint)
+		if err != nil {
+			gs.Log.Error("Error resolving Logstash plugin %s: %s", name, err.Error())
+			return err
+		}
+		if isLocked && locked.SHA256 != "" && plugin.SHA256 != "" && locked.SHA256 != plugin.SHA256 {
+			err := fmt.Errorf("the checksum of %s does not match the checksum of %s", plugin.File, conf.LockFile)
+			gs.Log.Error("Error installing Logstash plugin %s: %s", name, err.Error())
+			return err
+		}
+		if offline && plugin.Source == conf.PluginSourceRubygems {
+			unresolved = append(unresolved, name)
+		}
+		resolved.Plugins = append(resolved.Plugins, plugin)
+		constraints[name] = constraint
+	}
+
+	// plugins installed online by an earlier staging may still be cached
+	cacheKey := resolved.CacheKey()
+	if installed, ok := gs.RestorePluginCache(cacheKey); ok {
+		return gs.WritePluginLock(installed)
+	}
+	if len(unresolved) > 0 {
+		gs.reportUnresolvedPlugins(unresolved, constraints)
+		return fmt.Errorf("%d Logstash plugin(s) not found locally, installations from rubygems are not allowed offline", len(unresolved))
+	}
+
+	before := gs.bundleEntries()
+	if err := gs.RunPluginInstalls(conf.PluginInstalls(resolved.Plugins)); err != nil {
+		return err
+	}
+
+	installed := conf.PluginLock{Logstash: gs.Logstash.Version}
+	for _, plugin := range resolved.Plugins {
+		if plugin.Source == conf.PluginSourceRubygems {
+			// the version of an online installation is known after the installation only
+			plugin.Version, plugin.SHA256 = gs.InstalledPlugin(plugin.Name)
+			if plugin.Version == "" || !conf.SatisfiesVersion(plugin.Version, constraints[plugin.Name]) {
+				err := fmt.Errorf("installed version %q does not satisfy %q", plugin.Version, constraints[plugin.Name])
+				gs.Log.Error("Error installing Logstash plugin %s: %s", plugin.Name, err.Error())
+				return err
+			}
+		}
+		gs.Log.Info("       %s %s (%s)", plugin.Name, plugin.Version, plugin.Source)
+
+		if plugin.File != "" {
+			plugin.File = filepath.Base(plugin.File)
+		}
+		installed.Plugins = append(installed.Plugins, plugin)
+	}
+
+	// the next staging resolves the versions of the lock file, the cache is
+	// stored with the key of the installed versions therefore
+	gs.SavePluginCache(installed.CacheKey(), installed, addedEntries(before, gs.bundleEntries()))
+	return gs.WritePluginLock(installed)
+}
+
+// RunPluginInstalls runs logstash-plugin install for every group of plugins.
+// A failed run installs none of its plugins, they are installed one by one
+// then to report every failing plugin.
+func (gs *Supplier) RunPluginInstalls(installs []conf.PluginInstall) error {
+	failed := []string{}
+	for _, install := range installs {
+		out, err := exec.Command(fmt.Sprintf("%s/bin/logstash-plugin", gs.Logstash.StagingLocation), install.Args...).CombinedOutput()
+		if err == nil {
+			continue
+		}
+		if len(install.Plugins) == 1 {
+			gs.Log.Error("%s", out)
+			gs.Log.Error("Error installing Logstash plugin %s: %s", install.Plugins[0].Name, err.Error())
+			failed = append(failed, install.Plugins[0].Name)
+			continue
+		}
+
+		gs.Log.Warning("Installing %d Logstash plugins at once failed, installing them one by one", len(install.Plugins))
+		for _, plugin := range install.Plugins {
+			single := conf.PluginInstalls([]conf.LockedPlugin{plugin})[0]
+			out, err := exec.Command(fmt.Sprintf("%s/bin/logstash-plugin", gs.Logstash.StagingLocation), single.Args...).CombinedOutput()
+			if err != nil {
+				gs.Log.Error("%s", out)
+				gs.Log.Error("Error installing Logstash plugin %s: %s", plugin.Name, err.Error())
+				failed = append(failed, plugin.Name)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to install the Logstash plugins %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// reportUnresolvedPlugins logs the plugins without a local plugin file and
+// the sources searched for them.
+func (gs *Supplier) reportUnresolvedPlugins(names []string, constraints map[string]string) {
+	reason := "buildpack.offline is set"
+	if !gs.LogstashConfig.Buildpack.IsSet("offline") {
+		reason = "the buildpack is cached (offline)"
+	}
+	gs.Log.Error("Logstash plugins can not be installed from rubygems, %s. No local plugin file found for:", reason)
+	for _, name := range names {
+		if constraints[name] != "" {
+			gs.Log.Error("  %s (version %s)", name, constraints[name])
 		} else {
-			pluginToInstall = key // Prio 4 (online installation)
+			gs.Log.Error("  %s", name)
+		}
+	}
+	gs.Log.Error("Searched in:")
+	for _, source := range gs.PluginSources() {
+		gs.Log.Error("  %s: %s", source.Name, source.Dir)
+	}
+	gs.Log.Error("Put the plugin files (<plugin name>-<version>.gem) into the plugins folder of the app.")
+}
+
+func (gs *Supplier) sortedPluginsToInstall() []string {
+	names := []string{}
+	for name := range gs.PluginsToInstall {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolvePlugin returns the plugin file (with its checksum) of the highest
+// version satisfying the constraint from the first local source providing
+// one. Plugins not found locally are installed from rubygems, the version is
+// only given for exact versions then.
+func (gs *Supplier) ResolvePlugin(name, constraint string) (conf.LockedPlugin, error) {
+	for _, source := range gs.PluginSources() {
+		files, _ := gs.ReadLocalPlugins(source.Dir)
+		file, version := conf.SelectPluginFile(name, constraint, files)
+		if file == "" {
+			continue
+		}
+		checksum, err := sha256File(filepath.Join(source.Dir, file))
+		if err != nil {
+			return conf.LockedPlugin{}, err
+		}
+		return conf.LockedPlugin{Name: name, Version: version, Source: source.Name, File: filepath.Join(source.Dir, file), SHA256: checksum}, nil
+	}
+
+	plugin := conf.LockedPlugin{Name: name, Source: conf.PluginSourceRubygems}
+	if constraint != "" {
+		version, ok := conf.ExactVersion(constraint)
+		if !ok && !gs.IsOffline() {
+			gs.Log.Warning("Plugin %s is installed from rubygems, the version %q is checked after the installation", name, constraint)
 		}
+		plugin.Version = version
+	}
+	return plugin, nil
+}
+
+// A PluginSource is a directory with plugin files.
+type PluginSource struct {
+	Name string
+	Dir  string
+}
+
+// PluginSources returns the local sources of plugin files in the order they
+// are searched, x-pack and logstash-plugins are only available if they have
+// been installed.
+func (gs *Supplier) PluginSources() []PluginSource {
+	all := []PluginSource{
+		{conf.PluginSourceXPack, gs.XPack.StagingLocation},                     // Prio 1 (offline installation)
+		{conf.PluginSourceDefault, gs.LogstashPlugins.StagingLocation},         // Prio 2 (offline installation)
+		{conf.PluginSourceApp, filepath.Join(gs.Stager.BuildDir(), "plugins")}, // Prio 3 (offline installation)
+	}
 
-		if strings.HasSuffix(pluginToInstall, ".zip") {
-			pluginToInstall = "file://" + pluginToInstall
+	sources := []PluginSource{}
+	for _, source := range all {
+		if source.Dir != "" {
+			sources = append(sources, source)
 		}
+	}
+	return sources
+}
+
+// IsOffline returns whether plugins must not be installed from rubygems:
+// buildpack.offline or, if it is not set, a cached buildpack.
+func (gs *Supplier) IsOffline() bool {
+	if gs.LogstashConfig.Buildpack.IsSet("offline") {
+		return gs.LogstashConfig.Buildpack.Offline
+	}
+	return gs.Manifest.IsCached()
+}
 
-		//Install Plugin
-		out, err := exec.Command(fmt.Sprintf("%s/bin/logstash-plugin", gs.Logstash.StagingLocation), "install", pluginToInstall).CombinedOutput()
+// InstalledPlugin returns the version of an installed plugin and the checksum
+// of its gem file in the cache of the Logstash bundle, if there is one.
+func (gs *Supplier) InstalledPlugin(name string) (version, checksum string) {
+	gemsDir := filepath.Join(gs.Logstash.StagingLocation, "vendor", "bundle", "jruby", "*")
+	dirs, _ := filepath.Glob(filepath.Join(gemsDir, "gems", name+"-[0-9]*"))
+	for _, dir := range dirs {
+		n, v, ok := conf.ParsePluginFile(filepath.Base(dir) + ".gem")
+		if ok && n == name && (version == "" || conf.SatisfiesVersion(v, ">"+version)) {
+			version = v
+		}
+	}
+	if version == "" {
+		return "", ""
+	}
+
+	gems, _ := filepath.Glob(filepath.Join(gemsDir, "cache", name+"-"+version+"*.gem"))
+	if len(gems) > 0 {
+		checksum, _ = sha256File(gems[0])
+	}
+	return version, checksum
+}
+
+// ReadPluginLock reads the lock file of the app, or the one of the previous
+// staging from the cache.
+func (gs *Supplier) ReadPluginLock() conf.PluginLock {
+	lock := conf.PluginLock{}
+	for _, file := range []string{filepath.Join(gs.Stager.BuildDir(), conf.LockFile), filepath.Join(gs.Stager.CacheDir(), conf.LockFile)} {
+		data, err := ioutil.ReadFile(file)
 		if err != nil {
-			gs.Log.Error(string(out))
-			gs.Log.Error("Error installing Logstash plugin %s: %s", key, err.Error())
+			continue
+		}
+		if err := lock.Parse(data); err != nil {
+			gs.Log.Warning("Ignoring invalid %s: %s", file, err.Error())
+			continue
+		}
+		gs.Log.Info("       Using plugin versions of %s", file)
+		return lock
+	}
+	return lock
+}
+
+// WritePluginLock writes the lock file to the app and to the cache.
+func (gs *Supplier) WritePluginLock(lock conf.PluginLock) error {
+	data, err := lock.Bytes()
+	if err != nil {
+		return err
+	}
+	for _, file := range []string{filepath.Join(gs.Stager.BuildDir(), conf.LockFile), filepath.Join(gs.Stager.CacheDir(), conf.LockFile)} {
+		if err := ioutil.WriteFile(file, data, 0644); err != nil {
+			gs.Log.Error("Unable to write %s: %s", file, err.Error())
 			return err
 		}
 	}
+	return nil
+}
+
+// pluginCachePrefix is the prefix of the cached plugin trees in DepCacheDir.
+const pluginCachePrefix = "plugins-"
+
+// bundleEntries returns the entries of the gem directories of the bundle of
+// Logstash, relative to the bundle (e.g.
+// jruby/2.3.0/gems/logstash-input-kafka-7.0.6 or
+// jruby/2.3.0/specifications/logstash-input-kafka-7.0.6.gemspec).
+func (gs *Supplier) bundleEntries() map[string]bool {
+	bundleDir := filepath.Join(gs.Logstash.StagingLocation, "vendor", "bundle")
+	entries := map[string]bool{}
+	paths, _ := filepath.Glob(filepath.Join(bundleDir, "*", "*", "*", "*"))
+	for _, path := range paths {
+		if rel, err := filepath.Rel(bundleDir, path); err == nil {
+			entries[rel] = true
+		}
+	}
+	return entries
+}
+
+// addedEntries returns the bundle entries added by logstash-plugin install:
+// the installed plugins and their dependencies.
+func addedEntries(before, after map[string]bool) []string {
+	added := []string{}
+	for entry := range after {
+		if !before[entry] {
+			added = append(added, entry)
+		}
+	}
+	sort.Strings(added)
+	return added
+}
+
+// RestorePluginCache restores the plugins cached with the key into the bundle
+// of Logstash, it returns the lock of the cached plugins.
+func (gs *Supplier) RestorePluginCache(key string) (conf.PluginLock, bool) {
+	lock := conf.PluginLock{}
+	cacheDir := filepath.Join(gs.DepCacheDir, pluginCachePrefix+key)
+
+	data, err := ioutil.ReadFile(filepath.Join(cacheDir, conf.LockFile))
+	if err != nil || lock.Parse(data) != nil {
+		return lock, false
+	}
+
+	entries, _ := filepath.Glob(filepath.Join(cacheDir, "bundle", "*", "*", "*", "*"))
+	for _, entry := range entries {
+		if err == nil {
+			rel, _ := filepath.Rel(filepath.Join(cacheDir, "bundle"), entry)
+			err = copyEntry(entry, filepath.Join(gs.Logstash.StagingLocation, "vendor", "bundle", rel))
+		}
+	}
+	gemfiles, _ := filepath.Glob(filepath.Join(cacheDir, "Gemfile*"))
+	for _, gemfile := range gemfiles {
+		if err == nil {
+			err = libbuildpack.CopyFile(gemfile, filepath.Join(gs.Logstash.StagingLocation, filepath.Base(gemfile)))
+		}
+	}
+	if err != nil {
+		// Logstash is installed again from the dependency cache by the next staging
+		gs.Log.Error("Unable to restore the cached Logstash plugins: %s", err.Error())
+		return lock, false
+	}
+
+	gs.markPluginCache(pluginCachePrefix + key)
+	gs.Log.Info("       Restored the plugins from the cache: %s", strings.Join(lock.Names(), ", "))
+	return lock, true
+}
+
+// SavePluginCache caches the bundle entries added by the installation of the
+// plugins (see bundleEntries) and the Gemfiles with the key, the bundle of
+// Logstash itself is not cached. Other cached plugins are removed. Errors are
+// logged only, the cache is optional.
+func (gs *Supplier) SavePluginCache(key string, lock conf.PluginLock, entries []string) {
+	if gs.LogstashConfig.Buildpack.NoCache {
+		return
+	}
+	name := pluginCachePrefix + key
+	cacheDir := filepath.Join(gs.DepCacheDir, name)
+
+	err := os.RemoveAll(cacheDir)
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(cacheDir, "bundle"), 0755)
+	}
+	for _, entry := range entries {
+		if err == nil {
+			err = copyEntry(filepath.Join(gs.Logstash.StagingLocation, "vendor", "bundle", entry), filepath.Join(cacheDir, "bundle", entry))
+		}
+	}
+	gemfiles, _ := filepath.Glob(filepath.Join(gs.Logstash.StagingLocation, "Gemfile*"))
+	for _, gemfile := range gemfiles {
+		if err == nil {
+			err = libbuildpack.CopyFile(gemfile, filepath.Join(cacheDir, filepath.Base(gemfile)))
+		}
+	}
+	// the lock file is written last, it marks the cache as complete
+	if err == nil {
+		var data []byte
+		if data, err = lock.Bytes(); err == nil {
+			err = ioutil.WriteFile(filepath.Join(cacheDir, conf.LockFile), data, 0644)
+		}
+	}
+	if err != nil {
+		gs.Log.Warning("Unable to cache the Logstash plugins: %s", err.Error())
+		os.RemoveAll(cacheDir)
+		return
+	}
+	gs.markPluginCache(name)
+}
 
+// copyEntry copies a file or a directory of the bundle.
+func copyEntry(src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return libbuildpack.CopyFile(src, dest)
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	return libbuildpack.CopyDirectory(src, dest)
+}
+
+// markPluginCache marks the cached plugin tree as in use and removes the other
+// cached plugin trees.
+func (gs *Supplier) markPluginCache(name string) {
+	for cachedDep := range gs.CachedDeps {
+		if cachedDep != name && strings.HasPrefix(cachedDep, pluginCachePrefix) {
+			gs.Log.Debug("--> deleting unused plugin cache '%s' from application cache", cachedDep)
+			gs.CachedDeps[cachedDep] = "deleted"
+			os.RemoveAll(filepath.Join(gs.DepCacheDir, cachedDep))
+		}
+	}
+	gs.CachedDeps[name] = "in use"
+}
+
+func sha256File(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// RemoveLogstashPlugins removes the plugins of remove-plugins and, in
+// minimal mode, every plugin of the Gemfile of Logstash which is neither
+// referenced by the pipeline configs, nor installed by the buildpack, nor
+// kept (keep-plugins). Codecs are kept in minimal mode, they are the defaults
+// of inputs and outputs.
+// logstash-plugin removes a single plugin per run. A plugin of minimal mode
+// which can not be removed (e.g. a dependency of another plugin) is kept.
+func (gs *Supplier) RemoveLogstashPlugins() error {
+	if len(gs.LogstashConfig.RemovePlugins) == 0 && !gs.LogstashConfig.Minimal {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(gs.Logstash.StagingLocation, "Gemfile"))
+	if err != nil {
+		gs.Log.Error("Unable to read the Gemfile of Logstash: %s", err.Error())
+		return err
+	}
+	removable := map[string]bool{}
+	for _, name := range conf.GemfilePlugins(string(data)) {
+		removable[name] = true
+	}
+
+	explicit := map[string]bool{}
+	for _, name := range gs.LogstashConfig.RemovePlugins {
+		if !removable[name] {
+			gs.Log.Warning("Plugin %s of remove-plugins is not installed with Logstash", name)
+			continue
+		}
+		explicit[name] = true
+	}
+
+	remove := []string{}
+	if gs.LogstashConfig.Minimal {
+		if keep, err := gs.KeptPlugins(); err != nil {
+			//e.g. a config file with template actions: its plugins are unknown, nothing can be removed safely
+			gs.Log.Warning("Unable to read the plugins of the pipeline configs, minimal keeps all plugins: %s", err.Error())
+		} else {
+			for name := range removable {
+				if !explicit[name] && !keep[name] && isMinimalCandidate(name) {
+					remove = append(remove, name)
+				}
+			}
+		}
+	}
+	for name := range explicit {
+		remove = append(remove, name)
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+	sort.Strings(remove)
+
+	gs.Log.Info("----> Removing Logstash plugins ...")
+	logstashPlugin := fmt.Sprintf("%s/bin/logstash-plugin", gs.Logstash.StagingLocation)
+	failed := []string{}
+	for _, name := range remove {
+		out, err := exec.Command(logstashPlugin, "remove", name).CombinedOutput()
+		switch {
+		case err == nil:
+			gs.Log.Info("       %s", name)
+			gs.RemovedPlugins = append(gs.RemovedPlugins, name)
+		case explicit[name]:
+			gs.Log.Error("%s", out)
+			gs.Log.Error("Error removing Logstash plugin %s: %s", name, err.Error())
+			failed = append(failed, name)
+		default:
+			// e.g. a dependency of another plugin
+			gs.Log.Warning("Keeping Logstash plugin %s, it can not be removed: %s", name, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to remove the Logstash plugins %s", strings.Join(failed, ", "))
+	}
 	return nil
 }
 
+// isMinimalCandidate returns whether minimal mode may remove the plugin: an
+// input, filter or output plugin.
+func isMinimalCandidate(name string) bool {
+	for _, pluginType := range []string{"input", "filter", "output"} {
+		if strings.HasPrefix(name, pipeline.GemName(pluginType, "")) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeptPlugins returns the plugins minimal mode keeps: the plugins referenced
+// by the pipeline configs, the plugins installed by the buildpack (Logstash
+// file and templates) and the plugins of keep-plugins.
+func (gs *Supplier) KeptPlugins() (map[string]bool, error) {
+	keep := map[string]bool{}
+	for _, dir := range gs.PipelineConfigDirs() {
+		plugins, err := pipeline.DirPlugins(dir)
+		if err != nil {
+			return nil, err
+		}
+		gs.Log.Debug("Plugins referenced by the config files of %s: %s", dir, strings.Join(plugins, ", "))
+		for _, name := range plugins {
+			keep[name] = true
+		}
+	}
+
+	for _, plugin := range gs.LogstashConfig.Plugins {
+		keep[plugin.Name] = true
+	}
+	for _, t := range gs.TemplatesToInstall {
+		for _, name := range t.Plugins {
+			keep[name] = true
+		}
+	}
+	for _, name := range gs.LogstashConfig.KeepPlugins {
+		keep[name] = true
+	}
+	return keep, nil
+}
+
+// PipelineConfigDirs returns the directories with the config files of the
+// pipelines: the config files of the app and the rendered config templates.
+func (gs *Supplier) PipelineConfigDirs() []string {
+	if len(gs.LogstashConfig.Pipelines) == 0 {
+		return []string{filepath.Join(gs.Stager.BuildDir(), "conf.d"), filepath.Join(gs.Stager.DepDir(), "conf.d")}
+	}
+
+	dirs := []string{}
+	for _, p := range gs.LogstashConfig.Pipelines {
+		dirs = append(dirs, filepath.Join(gs.Stager.BuildDir(), p.ConfigDir), filepath.Join(gs.Stager.DepDir(), "pipelines", p.ID))
+	}
+	return dirs
+}
+
 func (gs *Supplier) CheckLogstash() error {
 
 	gs.Log.Info("----> Starting Logstash config check...")
 
-	// template processing for check
-	templateDir := filepath.Join(gs.Stager.DepDir(), "conf.d")
-	destDir := filepath.Join(gs.Stager.DepDir(), "logstash.conf.d")
-	err := exec.Command(fmt.Sprintf("%s/gte", gs.GTE.StagingLocation), templateDir, destDir).Run()
-	if err != nil {
-		gs.Log.Error("Error processing templates for Logstash config check: %s", err.Error())
+	if len(gs.LogstashConfig.Pipelines) == 0 {
+		templateDir := filepath.Join(gs.Stager.DepDir(), "conf.d")
+		destDir := filepath.Join(gs.Stager.DepDir(), "logstash.conf.d")
+		if err := gs.CheckLogstashConfig([]string{templateDir}, destDir); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range gs.LogstashConfig.Pipelines {
+			gs.Log.Info("  --> Pipeline %s", p.ID)
+
+			templateDirs := []string{}
+			if gs.PipelineConfigFilesExist(p) {
+				templateDirs = append(templateDirs, filepath.Join(gs.Stager.BuildDir(), p.ConfigDir))
+			}
+			templateDirs = append(templateDirs, filepath.Join(gs.Stager.DepDir(), "pipelines", p.ID))
+
+			destDir := filepath.Join(gs.Stager.DepDir(), "logstash.pipelines.d", p.ID)
+			if err := gs.CheckLogstashConfig(templateDirs, destDir); err != nil {
+				return err
+			}
+		}
+	}
+
+	gs.Log.Info("  --> Finished Logstash config check...")
+
+	return nil
+}
+
+// CheckLogstashConfig processes the templates in templateDirs into destDir and
+// runs the Logstash config test on it.
+func (gs *Supplier) CheckLogstashConfig(templateDirs []string, destDir string) error {
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
 		return err
 	}
 
-	// list files in logstash.conf.d
+	// template processing for check
+	for _, templateDir := range templateDirs {
+		err := render.New([2]string{}).RenderDir(templateDir, destDir)
+		if err != nil {
+			gs.Log.Error("Error processing templates for Logstash config check: %s", err.Error())
+			return err
+		}
+	}
+
+	// list files in destDir
 	file, err := os.Open(destDir)
 	if err != nil {
-		gs.Log.Error("  --> failed opening logstash.conf.d directory: %s", err)
+		gs.Log.Error("  --> failed opening %s directory: %s", filepath.Base(destDir), err)
 		return err
 	}
 	defer file.Close()
 
-	gs.Log.Info("  --> Listing files in logstash.conf.d directory ...")
+	gs.Log.Info("  --> Listing files in %s directory ...", filepath.Base(destDir))
 	list, _ := file.Readdirnames(0) // 0 to read all files
 	found := false
 	for _, name := range list {
 		found = true
-		gs.Log.Info("      " + name)
+		gs.Log.Info("      %s", name)
 	}
 	if !found {
 		gs.Log.Warning("      " + "no files found")
@@ -951,14 +1883,12 @@ func (gs *Supplier) CheckLogstash() error {
 	gs.Log.Info("  --> Checking Logstash config ...")
 	// check logstash config
 	out, err := exec.Command(fmt.Sprintf("%s/bin/logstash", gs.Logstash.StagingLocation), "-f", destDir, "-t").CombinedOutput()
-	gs.Log.Info(string(out))
+	gs.Log.Info("%s", out)
 	if err != nil {
 		gs.Log.Error("Error checking Logstash config: %s", err.Error())
 		return err
 	}
 
-	gs.Log.Info("  --> Finished Logstash config check...")
-
 	return nil
 }
 
@@ -1003,14 +1933,3 @@ func (gs *Supplier) ReadLocalPlugins(filePath string) ([]string, error) {
 
 	return list, nil
 }
-
-func (gs *Supplier) GetLocalPlugin(pluginName string, pluginFileNames []string) string {
-
-	for i := 0; i < len(pluginFileNames); i++ {
-		if strings.HasPrefix(pluginFileNames[i], pluginName) {
-			return pluginFileNames[i]
-		}
-	}
-
-	return ""
-}
@@ -6,8 +6,8 @@ import (
 	"github.com/andibrunner/libbuildpack"
 	"path/filepath"
 	"io/ioutil"
-	"fmt"
 	"logstash/util"
+	conf "logstash/config"
 )
 
 
@@ -19,7 +19,7 @@ func (gs *Supplier) NewDependency(name string, versionParts int, configVersion s
 	var dependency = Dependency{Name: name, VersionParts: versionParts, ConfigVersion: configVersion}
 
 	if parsedVersion, err := gs.SelectDependencyVersion(dependency); err != nil {
-		gs.Log.Error("Unable to determine the version of %s: %s", dependency, err.Error())
+		gs.Log.Error("Unable to determine the version of %s: %s", dependency.Name, err.Error())
 		return dependency, err
 	} else {
 		dependency.Version = parsedVersion
@@ -57,7 +57,7 @@ func (gs *Supplier) ReadCachedDependencies() error {
 	}
 
 	for _, dirEntry := range cacheDir{
-		gs.Log.Debug(fmt.Sprintf("--> added dependency '%s' to cache list", dirEntry.Name()))
+		gs.Log.Debug("--> added dependency '%s' to cache list", dirEntry.Name())
 		gs.CachedDeps[dirEntry.Name()] = ""
 	}
 
@@ -73,7 +73,7 @@ func (gs *Supplier) InstallDependency(dependency Dependency) error {
 	//check if there are other cached versions of the same dependency
 	for cachedDep := range gs.CachedDeps{
 		if cachedDep != dependency.DirName && strings.HasPrefix(cachedDep, dependency.Name + "-") {
-			gs.Log.Debug(fmt.Sprintf("--> deleting unused dependency version '%s' from application cache", cachedDep))
+			gs.Log.Debug("--> deleting unused dependency version '%s' from application cache", cachedDep)
 			gs.CachedDeps[cachedDep] = "deleted"
 			os.RemoveAll(filepath.Join(gs.DepCacheDir, cachedDep))
 		}
@@ -97,7 +97,7 @@ func (gs *Supplier) RemoveUnusedDependencies () error{
 
 	for cachedDep, value := range gs.CachedDeps{
 		if value == "" {
-			gs.Log.Debug(fmt.Sprintf("--> deleting unused dependency '%s' from application cache", cachedDep))
+			gs.Log.Debug("--> deleting unused dependency '%s' from application cache", cachedDep)
 			os.RemoveAll(filepath.Join(gs.DepCacheDir, cachedDep))
 		}
 	}
@@ -135,6 +135,16 @@ func (gs *Supplier) parseDependencyVersion(dependency Dependency, partialDepende
 	return expandedVer, nil
 }
 
+// ContainerMemoryLimit returns the memory limit in MB from VCAP_APPLICATION,
+// 0 if it is not available (yet).
+func (gs *Supplier) ContainerMemoryLimit() int {
+	vcapApp := conf.VcapApp{}
+	if err := vcapApp.Parse([]byte(os.Getenv("VCAP_APPLICATION"))); err != nil || vcapApp.Limits == nil {
+		return 0
+	}
+	return vcapApp.Limits.Mem
+}
+
 func (gs *Supplier) EvalRuntimeLocation(dependency Dependency) string {
 	return filepath.Join(gs.Stager.DepsIdx(), dependency.DirName)
 }
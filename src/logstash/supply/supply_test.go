@@ -1,17 +1,18 @@
 package supply_test
 
 import (
-	"golang"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"bytes"
 
+	conf "logstash/config"
 	"logstash/supply"
 
-	"github.com/cloudfoundry/libbuildpack"
-	"github.com/cloudfoundry/libbuildpack/ansicleaner"
+	"github.com/andibrunner/libbuildpack"
+	"github.com/andibrunner/libbuildpack/ansicleaner"
 
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
@@ -20,27 +21,72 @@ import (
 
 //go:generate mockgen -source=supply.go --destination=mocks_test.go --package=supply_test
 
+// fakeLogstashPlugin records its arguments and installs the plugins into the
+// bundle: plugin files with the version of the file, gems with --version or
+// 1.0.0. Plugins named *broken* can not be installed, plugins named
+// *dependency* can not be removed.
+const fakeLogstashPlugin = `#!/bin/sh
+home=$(dirname "$0")/..
+gems="$home/vendor/bundle/jruby/2.3.0/gems"
+echo "$@" >> "$home/calls"
+case "$1" in
+install)
+	shift
+	version=1.0.0
+	while [ $# -gt 0 ]; do
+		case "$1" in
+		*broken*) echo "unable to install $1"; exit 1 ;;
+		--version) version=$2; shift ;;
+		*.gem) mkdir -p "$gems/$(basename "$1" .gem)" ;;
+		*) mkdir -p "$gems/$1-$version" ;;
+		esac
+		shift
+	done ;;
+remove)
+	case "$2" in
+	*dependency*) echo "$2 is a dependency of another plugin"; exit 1 ;;
+	esac
+	rm -rf "$gems/$2"-[0-9]* ;;
+esac
+`
+
 var _ = Describe("Supply", func() {
 	var (
 		buildDir     string
+		cacheDir     string
 		depsDir      string
 		depsIdx      string
+		logstashDir  string
 		gs           *supply.Supplier
 		logger       *libbuildpack.Logger
 		buffer       *bytes.Buffer
 		err          error
 		mockCtrl     *gomock.Controller
 		mockManifest *MockManifest
-		goVersion    string
-		vendorTool   string
-		godep        golang.Godep
+		cached       bool
 	)
 
+	// calls returns the runs of logstash-plugin
+	calls := func() []string {
+		data, _ := ioutil.ReadFile(filepath.Join(logstashDir, "calls"))
+		if len(data) == 0 {
+			return []string{}
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+
+	gem := func(name string) string {
+		return filepath.Join(logstashDir, "vendor", "bundle", "jruby", "2.3.0", "gems", name)
+	}
+
 	BeforeEach(func() {
-		buildDir, err = ioutil.TempDir("", "go-buildpack.build.")
+		buildDir, err = ioutil.TempDir("", "logstash-buildpack.build.")
+		Expect(err).To(BeNil())
+
+		cacheDir, err = ioutil.TempDir("", "logstash-buildpack.cache.")
 		Expect(err).To(BeNil())
 
-		depsDir, err = ioutil.TempDir("", "go-buildpack.deps.")
+		depsDir, err = ioutil.TempDir("", "logstash-buildpack.deps.")
 		Expect(err).To(BeNil())
 
 		depsIdx = "04"
@@ -48,433 +94,284 @@ var _ = Describe("Supply", func() {
 		err = os.MkdirAll(filepath.Join(depsDir, depsIdx), 0755)
 		Expect(err).To(BeNil())
 
+		logstashDir, err = ioutil.TempDir("", "logstash-buildpack.logstash.")
+		Expect(err).To(BeNil())
+
+		Expect(os.MkdirAll(filepath.Join(logstashDir, "bin"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(logstashDir, "bin", "logstash-plugin"), []byte(fakeLogstashPlugin), 0755)).To(Succeed())
+		Expect(os.MkdirAll(gem("logstash-core-6.0.0"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(logstashDir, "Gemfile"), []byte("gem \"logstash-core\"\n"), 0644)).To(Succeed())
+
 		buffer = new(bytes.Buffer)
 
 		logger = libbuildpack.NewLogger(ansicleaner.New(buffer))
 
 		mockCtrl = gomock.NewController(GinkgoT())
 		mockManifest = NewMockManifest(mockCtrl)
+		cached = false
 	})
 
 	JustBeforeEach(func() {
-		args := []string{buildDir, "", depsDir, depsIdx}
+		args := []string{buildDir, cacheDir, depsDir, depsIdx}
 		stager := libbuildpack.NewStager(args, logger, &libbuildpack.Manifest{})
 
+		mockManifest.EXPECT().IsCached().Return(cached).AnyTimes()
+
 		gs = &supply.Supplier{
-			Stager:     stager,
-			Manifest:   mockManifest,
-			Log:        logger,
-			GoVersion:  goVersion,
-			VendorTool: vendorTool,
-			Godep:      godep,
+			Stager:           stager,
+			Manifest:         mockManifest,
+			Log:              logger,
+			CachedDeps:       map[string]string{},
+			DepCacheDir:      filepath.Join(cacheDir, "dependencies"),
+			Logstash:         supply.Dependency{Name: "logstash", Version: "6.0.0", StagingLocation: logstashDir},
+			PluginsToInstall: map[string]string{},
 		}
 	})
 
 	AfterEach(func() {
 		mockCtrl.Finish()
 
-		err = os.RemoveAll(buildDir)
-		Expect(err).To(BeNil())
-
-		err = os.RemoveAll(depsDir)
-		Expect(err).To(BeNil())
+		for _, dir := range []string{buildDir, cacheDir, depsDir, logstashDir} {
+			err = os.RemoveAll(dir)
+			Expect(err).To(BeNil())
+		}
 	})
 
-	Describe("SelectVendorTool", func() {
-		Context("There is a Godeps.json", func() {
-			var (
-				godepsJson         string
-				godepsJsonContents string
-			)
-
-			JustBeforeEach(func() {
-				err = os.MkdirAll(filepath.Join(buildDir, "Godeps"), 0755)
-				Expect(err).To(BeNil())
+	Describe("InstallLogstashPlugins", func() {
+		BeforeEach(func() {
+			Expect(os.MkdirAll(filepath.Join(buildDir, "plugins"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(buildDir, "plugins", "logstash-input-kafka-7.0.6.gem"), []byte("kafka"), 0644)).To(Succeed())
+		})
 
-				godepsJson = filepath.Join(buildDir, "Godeps", "Godeps.json")
-				err = ioutil.WriteFile(godepsJson, []byte(godepsJsonContents), 0644)
-				Expect(err).To(BeNil())
-			})
+		readLock := func(file string) conf.PluginLock {
+			lock := conf.PluginLock{}
+			data, err := ioutil.ReadFile(file)
+			Expect(err).To(BeNil())
+			Expect(lock.Parse(data)).To(Succeed())
+			return lock
+		}
 
-			Context("the json is valid", func() {
-				BeforeEach(func() {
-					godepsJsonContents = `
-{
-	"ImportPath": "go-online",
-	"GoVersion": "go1.6",
-	"Deps": []
-}
-`
-				})
-				It("sets the tool to godep", func() {
-					err = gs.SelectVendorTool()
-					Expect(err).To(BeNil())
-
-					Expect(gs.VendorTool).To(Equal("godep"))
-				})
-				It("logs that it is checking the Godeps.json file", func() {
-					err = gs.SelectVendorTool()
-					Expect(err).To(BeNil())
-
-					Expect(buffer.String()).To(ContainSubstring("-----> Checking Godeps/Godeps.json file"))
-				})
-				It("stores the Godep info in the supplier struct", func() {
-					err = gs.SelectVendorTool()
-					Expect(err).To(BeNil())
-
-					Expect(gs.Godep.ImportPath).To(Equal("go-online"))
-					Expect(gs.Godep.GoVersion).To(Equal("go1.6"))
-
-					var empty []string
-					Expect(gs.Godep.Packages).To(Equal(empty))
-				})
-
-				Context("godeps workspace exists", func() {
-					BeforeEach(func() {
-						err = os.MkdirAll(filepath.Join(buildDir, "Godeps", "_workspace", "src"), 0755)
-						Expect(err).To(BeNil())
-					})
-
-					It("sets Godep.WorkspaceExists to true", func() {
-						err = gs.SelectVendorTool()
-						Expect(err).To(BeNil())
-
-						Expect(gs.Godep.WorkspaceExists).To(BeTrue())
-					})
-				})
-
-				Context("godeps workspace does not exist", func() {
-					It("sets Godep.WorkspaceExists to false", func() {
-						err = gs.SelectVendorTool()
-						Expect(err).To(BeNil())
-
-						Expect(godep.WorkspaceExists).To(BeFalse())
-					})
-				})
-			})
+		It("writes the lock file with the installed versions", func() {
+			gs.PluginsToInstall = map[string]string{"logstash-input-kafka": "", "logstash-output-http": "", "logstash-filter-exact": "2.0.0"}
 
-			Context("bad Godeps.json file", func() {
-				BeforeEach(func() {
-					godepsJsonContents = "not actually JSON"
-				})
+			Expect(gs.InstallLogstashPlugins()).To(Succeed())
 
-				It("logs that the Godeps.json file is invalid and returns an error", func() {
-					err = gs.SelectVendorTool()
-					Expect(err).NotTo(BeNil())
+			lock := readLock(filepath.Join(buildDir, conf.LockFile))
+			Expect(lock.Logstash).To(Equal("6.0.0"))
+			Expect(lock.Names()).To(Equal([]string{"logstash-filter-exact 2.0.0", "logstash-input-kafka 7.0.6", "logstash-output-http 1.0.0"}))
+			kafka, _ := lock.Find("logstash-input-kafka")
+			Expect(kafka).To(Equal(conf.LockedPlugin{Name: "logstash-input-kafka", Version: "7.0.6", Source: conf.PluginSourceApp,
+				File: "logstash-input-kafka-7.0.6.gem", SHA256: "cbbf241eebedba6fd5e657b3c560fc9dd63c0d3e0de6f816be60e86157ec872c"}))
+			http, _ := lock.Find("logstash-output-http")
+			Expect(http).To(Equal(conf.LockedPlugin{Name: "logstash-output-http", Version: "1.0.0", Source: conf.PluginSourceRubygems}))
 
-					Expect(buffer.String()).To(ContainSubstring("**ERROR** Bad Godeps/Godeps.json file"))
-				})
-			})
+			Expect(readLock(filepath.Join(cacheDir, conf.LockFile))).To(Equal(lock))
 		})
 
-		Context("there is a .godir file", func() {
-			BeforeEach(func() {
-				err = ioutil.WriteFile(filepath.Join(buildDir, ".godir"), []byte("xxx"), 0644)
-			})
+		It("installs the locked versions", func() {
+			lock := conf.PluginLock{Logstash: "6.0.0", Plugins: []conf.LockedPlugin{{Name: "logstash-output-http", Version: "0.9.0", Source: conf.PluginSourceRubygems}}}
+			data, err := lock.Bytes()
+			Expect(err).To(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(buildDir, conf.LockFile), data, 0644)).To(Succeed())
+			gs.PluginsToInstall = map[string]string{"logstash-output-http": ""}
 
-			It("logs that .godir is deprecated and returns an error", func() {
-				err = gs.SelectVendorTool()
-				Expect(err).NotTo(BeNil())
+			Expect(gs.InstallLogstashPlugins()).To(Succeed())
 
-				Expect(buffer.String()).To(ContainSubstring("**ERROR** Deprecated, .godir file found! Please update to supported Godep or Glide dependency managers."))
-				Expect(buffer.String()).To(ContainSubstring("See https://github.com/tools/godep or https://github.com/Masterminds/glide for usage information."))
-			})
+			Expect(calls()).To(Equal([]string{"install --version 0.9.0 logstash-output-http"}))
 		})
 
-		Context("there is a glide.yaml file", func() {
-			BeforeEach(func() {
-				err = ioutil.WriteFile(filepath.Join(buildDir, "glide.yaml"), []byte("xxx"), 0644)
-				Expect(err).To(BeNil())
-			})
+		It("installs plugin files and gems with as few runs as possible", func() {
+			gs.PluginsToInstall = map[string]string{"logstash-input-kafka": "", "logstash-output-http": "", "logstash-filter-exact": "2.0.0"}
 
-			It("sets the tool to glide", func() {
-				err = gs.SelectVendorTool()
-				Expect(err).To(BeNil())
+			Expect(gs.InstallLogstashPlugins()).To(Succeed())
 
-				Expect(gs.VendorTool).To(Equal("glide"))
-			})
+			Expect(calls()).To(Equal([]string{
+				"install " + filepath.Join(buildDir, "plugins", "logstash-input-kafka-7.0.6.gem"),
+				"install logstash-output-http",
+				"install --version 2.0.0 logstash-filter-exact",
+			}))
 		})
 
-		Context("the app contains src/**/**/*.go", func() {
-			BeforeEach(func() {
-				err = os.MkdirAll(filepath.Join(buildDir, "src", "package"), 0755)
-				Expect(err).To(BeNil())
-
-				err = ioutil.WriteFile(filepath.Join(buildDir, "src", "package", "thing.go"), []byte("xxx"), 0644)
-				Expect(err).To(BeNil())
-			})
-
-			It("logs that gb is deprecated and returns an error", func() {
-				err = gs.SelectVendorTool()
-				Expect(err).NotTo(BeNil())
+		It("installs the plugins of a failed run one by one", func() {
+			gs.PluginsToInstall = map[string]string{"logstash-output-http": "", "logstash-output-broken": ""}
 
-				Expect(buffer.String()).To(ContainSubstring("**ERROR** Cloud Foundry does not support the GB package manager."))
-				Expect(buffer.String()).To(ContainSubstring("We currently only support the Godep and Glide package managers for go apps"))
-				Expect(buffer.String()).To(ContainSubstring("For support please file an issue: https://github.com/cloudfoundry/go-buildpack/issues"))
+			Expect(gs.InstallLogstashPlugins()).To(MatchError("unable to install the Logstash plugins logstash-output-broken"))
 
-			})
+			Expect(calls()).To(Equal([]string{
+				"install logstash-output-broken logstash-output-http",
+				"install logstash-output-broken",
+				"install logstash-output-http",
+			}))
+			Expect(buffer.String()).To(ContainSubstring("Error installing Logstash plugin logstash-output-broken"))
 		})
 
-		Context("none of the above", func() {
-			It("sets the tool to go_nativevendoring", func() {
-				err = gs.SelectVendorTool()
-				Expect(err).To(BeNil())
-
-				Expect(gs.VendorTool).To(Equal("go_nativevendoring"))
-			})
+		It("caches the installed gems only and restores them without logstash-plugin", func() {
+			gs.PluginsToInstall = map[string]string{"logstash-input-kafka": "", "logstash-output-http": ""}
+			Expect(gs.InstallLogstashPlugins()).To(Succeed())
+
+			caches, _ := filepath.Glob(filepath.Join(gs.DepCacheDir, "plugins-*"))
+			Expect(caches).To(HaveLen(1))
+			Expect(gs.CachedDeps).To(Equal(map[string]string{filepath.Base(caches[0]): "in use"}))
+			entries, _ := filepath.Glob(filepath.Join(caches[0], "bundle", "jruby", "2.3.0", "gems", "*"))
+			Expect(entries).To(ConsistOf(
+				filepath.Join(caches[0], "bundle", "jruby", "2.3.0", "gems", "logstash-input-kafka-7.0.6"),
+				filepath.Join(caches[0], "bundle", "jruby", "2.3.0", "gems", "logstash-output-http-1.0.0")))
+			Expect(filepath.Join(caches[0], "Gemfile")).To(BeARegularFile())
+
+			// a new staging with a fresh Logstash
+			Expect(os.RemoveAll(gem("logstash-input-kafka-7.0.6"))).To(Succeed())
+			Expect(os.RemoveAll(gem("logstash-output-http-1.0.0"))).To(Succeed())
+			Expect(os.Remove(filepath.Join(logstashDir, "calls"))).To(Succeed())
+
+			Expect(gs.InstallLogstashPlugins()).To(Succeed())
+
+			Expect(calls()).To(BeEmpty())
+			Expect(gem("logstash-input-kafka-7.0.6")).To(BeADirectory())
+			Expect(gem("logstash-output-http-1.0.0")).To(BeADirectory())
+			Expect(gem("logstash-core-6.0.0")).To(BeADirectory())
+			Expect(buffer.String()).To(ContainSubstring("Restored the plugins from the cache: logstash-input-kafka 7.0.6, logstash-output-http 1.0.0"))
 		})
-	})
-
-	Describe("InstallVendorTools", func() {
-		It("installs godep + glide to the depDir, creating a symlink in <depDir>/bin", func() {
-			godepInstallDir := filepath.Join(depsDir, depsIdx, "godep")
-			glideInstallDir := filepath.Join(depsDir, depsIdx, "glide")
 
-			mockManifest.EXPECT().InstallOnlyVersion("godep", godepInstallDir).Return(nil)
-			mockManifest.EXPECT().InstallOnlyVersion("glide", glideInstallDir).Return(nil)
+		It("does not cache with buildpack.no-cache", func() {
+			gs.LogstashConfig.Buildpack.NoCache = true
+			gs.PluginsToInstall = map[string]string{"logstash-output-http": ""}
+			Expect(gs.InstallLogstashPlugins()).To(Succeed())
 
-			err = gs.InstallVendorTools()
-			Expect(err).To(BeNil())
-
-			link, err := os.Readlink(filepath.Join(depsDir, depsIdx, "bin", "godep"))
-			Expect(err).To(BeNil())
-
-			Expect(link).To(Equal("../godep/bin/godep"))
-
-			link, err = os.Readlink(filepath.Join(depsDir, depsIdx, "bin", "glide"))
-			Expect(err).To(BeNil())
-
-			Expect(link).To(Equal("../glide/bin/glide"))
-		})
-	})
-
-	Describe("SelectGoVersion", func() {
-		BeforeEach(func() {
-			versions := []string{"1.8.0", "1.7.5", "1.7.4", "1.6.3", "1.6.4", "34.34.0", "1.14.3"}
-			mockManifest.EXPECT().AllDependencyVersions("go").Return(versions)
+			Expect(gs.DepCacheDir).NotTo(BeADirectory())
 		})
 
-		Context("godep", func() {
+		Context("offline", func() {
 			BeforeEach(func() {
-				vendorTool = "godep"
-				godep = golang.Godep{ImportPath: "go-online", GoVersion: "go1.6"}
+				cached = true
 			})
 
-			Context("GOVERSION not set", func() {
-				It("sets the go version from Godeps.json", func() {
-					err = gs.SelectGoVersion()
-					Expect(err).To(BeNil())
-
-					Expect(gs.GoVersion).To(Equal("1.6.4"))
-				})
-			})
+			It("installs local plugin files", func() {
+				gs.PluginsToInstall = map[string]string{"logstash-input-kafka": ""}
 
-			Context("GOVERSION is set", func() {
-				var oldGOVERSION string
-
-				BeforeEach(func() {
-					oldGOVERSION = os.Getenv("GOVERSION")
-					err = os.Setenv("GOVERSION", "go34.34")
-					Expect(err).To(BeNil())
-				})
-
-				AfterEach(func() {
-					err = os.Setenv("GOVERSION", oldGOVERSION)
-					Expect(err).To(BeNil())
-				})
-
-				It("sets the go version from GOVERSION and logs a warning", func() {
-					err = gs.SelectGoVersion()
-					Expect(err).To(BeNil())
-
-					Expect(gs.GoVersion).To(Equal("34.34.0"))
-					Expect(buffer.String()).To(ContainSubstring("**WARNING** Using $GOVERSION override.\n"))
-					Expect(buffer.String()).To(ContainSubstring("    $GOVERSION = go34.34\n"))
-					Expect(buffer.String()).To(ContainSubstring("If this isn't what you want please run:\n"))
-					Expect(buffer.String()).To(ContainSubstring("    cf unset-env <app> GOVERSION"))
-				})
+				Expect(gs.InstallLogstashPlugins()).To(Succeed())
+				Expect(calls()).To(HaveLen(1))
 			})
-		})
 
-		Context("glide or go_nativevendoring", func() {
-			Context("GOVERSION is notset", func() {
-				BeforeEach(func() {
-					vendorTool = "glide"
-					dep := libbuildpack.Dependency{Name: "go", Version: "1.14.3"}
-					mockManifest.EXPECT().DefaultVersion("go").Return(dep, nil)
-				})
+			It("lists the plugins which would be installed from rubygems", func() {
+				gs.PluginsToInstall = map[string]string{"logstash-input-kafka": "", "logstash-output-http": ">= 5.0"}
 
-				It("sets the go version to the default from the manifest.yml", func() {
-					err = gs.SelectGoVersion()
-					Expect(err).To(BeNil())
+				Expect(gs.InstallLogstashPlugins()).To(MatchError(ContainSubstring("1 Logstash plugin(s) not found locally")))
 
-					Expect(gs.GoVersion).To(Equal("1.14.3"))
-				})
+				Expect(calls()).To(BeEmpty())
+				Expect(buffer.String()).To(ContainSubstring("the buildpack is cached (offline)"))
+				Expect(buffer.String()).To(ContainSubstring("logstash-output-http (version >= 5.0)"))
+				Expect(buffer.String()).To(ContainSubstring("app: " + filepath.Join(buildDir, "plugins")))
 			})
 
-			Context("GOVERSION is set", func() {
-				var oldGOVERSION string
-
-				BeforeEach(func() {
-					oldGOVERSION = os.Getenv("GOVERSION")
-					err = os.Setenv("GOVERSION", "go34.34")
-					Expect(err).To(BeNil())
-					vendorTool = "go_nativevendoring"
-				})
+			It("installs from rubygems if buildpack.offline is false", func() {
+				Expect(gs.LogstashConfig.Parse([]byte("buildpack:\n  offline: false\n"))).To(Succeed())
+				gs.PluginsToInstall = map[string]string{"logstash-output-http": ""}
 
-				AfterEach(func() {
-					err = os.Setenv("GOVERSION", oldGOVERSION)
-					Expect(err).To(BeNil())
-				})
-
-				It("sets the go version from GOVERSION", func() {
-					err = gs.SelectGoVersion()
-					Expect(err).To(BeNil())
-
-					Expect(gs.GoVersion).To(Equal("34.34.0"))
-				})
+				Expect(gs.IsOffline()).To(BeFalse())
+				Expect(gs.InstallLogstashPlugins()).To(Succeed())
+				Expect(calls()).To(Equal([]string{"install logstash-output-http"}))
 			})
 		})
 	})
 
-	Describe("InstallGo", func() {
-		var (
-			goInstallDir string
-			dep          libbuildpack.Dependency
-		)
+	Describe("PrepareStagingEnvironment", func() {
+		var javaOpts, path string
 
 		BeforeEach(func() {
-			goVersion = "1.3.4"
-			goInstallDir = filepath.Join(depsDir, depsIdx, "go1.3.4")
-			dep = libbuildpack.Dependency{Name: "go", Version: "1.3.4"}
-			err = os.MkdirAll(filepath.Join(goInstallDir, "go"), 0755)
-			Expect(err).To(BeNil())
-			mockManifest.EXPECT().InstallDependency(dep, goInstallDir).Return(nil)
+			javaOpts, path = os.Getenv("LS_JAVA_OPTS"), os.Getenv("PATH")
 		})
 
-		It("Write GOROOT to envfile", func() {
-			err = gs.InstallGo()
-			Expect(err).To(BeNil())
-
-			contents, err := ioutil.ReadFile(filepath.Join(depsDir, depsIdx, "env", "GOROOT"))
-			Expect(err).To(BeNil())
-			Expect(string(contents)).To(Equal(filepath.Join(goInstallDir, "go")))
+		AfterEach(func() {
+			os.Setenv("LS_JAVA_OPTS", javaOpts)
+			os.Setenv("PATH", path)
 		})
 
-		It("installs go to the depDir, creating a symlink in <depDir>/bin", func() {
-			err = gs.InstallGo()
-			Expect(err).To(BeNil())
-
-			link, err := os.Readlink(filepath.Join(depsDir, depsIdx, "bin", "go"))
-			Expect(err).To(BeNil())
+		It("calculates the JVM memory", func() {
+			gs.VcapApp.Limits = &conf.Limits{Mem: 2048}
+			gs.LogstashConfig.ReservedMemory, gs.LogstashConfig.HeapPercentage = 0, 90
+			gs.LogstashConfig.Settings = map[string]interface{}{"pipeline.workers": 1}
 
-			Expect(link).To(Equal("../go1.3.4/go/bin/go"))
-
-		})
-	})
-
-	Describe("WritesGoRootToProfileD", func() {
-		BeforeEach(func() {
-			goVersion = "3.4.5"
+			Expect(gs.PrepareStagingEnvironment()).To(Succeed())
+			Expect(os.Getenv("LS_JAVA_OPTS")).To(HavePrefix("-Xmx1843m -Xms1843m "))
 		})
 
-		It("writes the goroot.sh script to <depDir>/profile.d", func() {
-			err = gs.WriteGoRootToProfileD()
-			Expect(err).To(BeNil())
-
-			contents, err := ioutil.ReadFile(filepath.Join(depsDir, depsIdx, "profile.d", "goroot.sh"))
-			Expect(err).To(BeNil())
+		It("fails if the memory does not fit", func() {
+			gs.VcapApp.Limits = &conf.Limits{Mem: 512}
+			gs.LogstashConfig.ReservedMemory, gs.LogstashConfig.HeapPercentage = 300, 90
+			gs.LogstashConfig.Settings = map[string]interface{}{"pipeline.workers": 1}
 
-			Expect(string(contents)).To(ContainSubstring("export GOROOT=$DEPS_DIR/04/go3.4.5/go"))
-			Expect(string(contents)).To(ContainSubstring("PATH=$PATH:$GOROOT/bin"))
+			Expect(gs.PrepareStagingEnvironment()).To(MatchError(ContainSubstring("the available memory of 212m leaves")))
+			Expect(buffer.String()).To(ContainSubstring("Unable to calculate the JVM memory with 1 pipeline workers"))
 		})
 
+		It("fails if the container memory limit is unknown", func() {
+			Expect(gs.PrepareStagingEnvironment()).To(MatchError("the container memory limit is unknown"))
+		})
 	})
 
-	Describe("WriteConfigYml", func() {
+	Describe("RemoveLogstashPlugins", func() {
+		const gemfile = `source "https://rubygems.org"
+gem "logstash-core", :path => "./logstash-core"
+gem "logstash-codec-plain"
+gem "logstash-filter-dependency"
+gem "logstash-input-tcp"
+gem "logstash-input-unused"
+gem "logstash-output-csv"
+`
+
 		BeforeEach(func() {
-			goVersion = "1.3.4"
+			Expect(ioutil.WriteFile(filepath.Join(logstashDir, "Gemfile"), []byte(gemfile), 0644)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(buildDir, "conf.d"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(buildDir, "conf.d", "logstash.conf"), []byte("input { tcp { port => 5000 } }\noutput { stdout {} }\n"), 0644)).To(Succeed())
 		})
-		type config struct {
-			Name   string `yaml:"name"`
-			Config struct {
-				GoVersion  string `yaml:"GoVersion"`
-				VendorTool string `yaml:"VendorTool"`
-				Godep      string `yaml:"Godep"`
-			} `yaml:"config"`
-		}
-		getConfig := func() config {
-			cfg := config{}
-			err = libbuildpack.NewYAML().Load(filepath.Join(depsDir, depsIdx, "config.yml"), &cfg)
-			Expect(err).To(BeNil())
-			return cfg
-		}
 
-		Context("The vendor tool is Godep", func() {
-			BeforeEach(func() {
-				vendorTool = "godep"
-				godep = golang.Godep{
-					ImportPath:      "an-import-path",
-					GoVersion:       "go1.3",
-					Packages:        []string{"package1", "package2"},
-					WorkspaceExists: true,
-				}
-			})
+		It("removes the unused plugins in minimal mode one by one and leaves the Gemfile alone", func() {
+			gs.LogstashConfig.Minimal = true
 
-			It("Writes the go version to config.yml", func() {
-				err = gs.WriteConfigYml()
-				Expect(err).To(BeNil())
+			Expect(gs.RemoveLogstashPlugins()).To(Succeed())
 
-				cfg := getConfig()
-				Expect(cfg.Config.GoVersion).To(Equal("1.3.4"))
-			})
-
-			It("Writes the vendor tool to config.yml", func() {
-				err = gs.WriteConfigYml()
-				Expect(err).To(BeNil())
+			Expect(calls()).To(Equal([]string{
+				"remove logstash-filter-dependency",
+				"remove logstash-input-unused",
+				"remove logstash-output-csv",
+			}))
+			Expect(gs.RemovedPlugins).To(Equal([]string{"logstash-input-unused", "logstash-output-csv"}))
+			Expect(buffer.String()).To(ContainSubstring("Keeping Logstash plugin logstash-filter-dependency, it can not be removed"))
+			Expect(ioutil.ReadFile(filepath.Join(logstashDir, "Gemfile"))).To(Equal([]byte(gemfile)))
+		})
 
-				cfg := getConfig()
-				Expect(cfg.Config.VendorTool).To(Equal("godep"))
-			})
+		It("keeps all plugins in minimal mode if a config file can not be parsed", func() {
+			gs.LogstashConfig.Minimal = true
+			Expect(ioutil.WriteFile(filepath.Join(buildDir, "conf.d", "filter.conf"), []byte("filter { {{ .Filter }} {} }\n"), 0644)).To(Succeed())
 
-			It("Writes the godep info to config.yml", func() {
-				godepsJsonContents := `{"ImportPath":"an-import-path","GoVersion":"go1.3","Packages":["package1","package2"],"WorkspaceExists":true}`
-				err = gs.WriteConfigYml()
-				Expect(err).To(BeNil())
+			Expect(gs.RemoveLogstashPlugins()).To(Succeed())
 
-				cfg := getConfig()
-				Expect(cfg.Config.Godep).To(Equal(godepsJsonContents))
-			})
+			Expect(calls()).To(BeEmpty())
+			Expect(buffer.String()).To(ContainSubstring("Unable to read the plugins of the pipeline configs, minimal keeps all plugins"))
 		})
 
-		Context("The vendor tool is not Godep", func() {
-			BeforeEach(func() {
-				vendorTool = "glide"
-			})
+		It("keeps the plugins of keep-plugins", func() {
+			gs.LogstashConfig.Minimal = true
+			gs.LogstashConfig.KeepPlugins = []string{"logstash-output-csv", "logstash-filter-dependency"}
 
-			It("Writes the go version to config.yml", func() {
-				err = gs.WriteConfigYml()
-				Expect(err).To(BeNil())
+			Expect(gs.RemoveLogstashPlugins()).To(Succeed())
 
-				cfg := getConfig()
-				Expect(cfg.Config.GoVersion).To(Equal("1.3.4"))
-			})
+			Expect(calls()).To(Equal([]string{"remove logstash-input-unused"}))
+		})
 
-			It("Writes the vendor tool to config.yml", func() {
-				err = gs.WriteConfigYml()
-				Expect(err).To(BeNil())
+		It("fails if a plugin of remove-plugins can not be removed", func() {
+			gs.LogstashConfig.RemovePlugins = []string{"logstash-output-csv", "logstash-filter-dependency", "logstash-output-missing"}
 
-				cfg := getConfig()
-				Expect(cfg.Config.VendorTool).To(Equal("glide"))
-			})
+			Expect(gs.RemoveLogstashPlugins()).To(MatchError("unable to remove the Logstash plugins logstash-filter-dependency"))
 
-			It("Does not write the godep info to config.yml", func() {
-				err = gs.WriteConfigYml()
-				Expect(err).To(BeNil())
+			Expect(calls()).To(Equal([]string{"remove logstash-filter-dependency", "remove logstash-output-csv"}))
+			Expect(gs.RemovedPlugins).To(Equal([]string{"logstash-output-csv"}))
+			Expect(buffer.String()).To(ContainSubstring("Plugin logstash-output-missing of remove-plugins is not installed with Logstash"))
+		})
 
-				cfg := getConfig()
-				Expect(cfg.Config.Godep).To(Equal(""))
-			})
+		It("removes nothing by default", func() {
+			Expect(gs.RemoveLogstashPlugins()).To(Succeed())
+			Expect(calls()).To(BeEmpty())
 		})
 	})
 })
@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 func TrimLines(text string) string {
@@ -29,4 +30,25 @@ func RemoveAllContents(dir string) error {
 		}
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// DirSize returns the size of all files in dir and its subdirectories in bytes.
+func DirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// ShellQuote quotes value with single quotes for a shell script, e.g. a
+// profile.d script, so the shell neither splits nor expands it.
+func ShellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}